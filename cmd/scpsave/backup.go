@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"scpsave/internal/savesync"
+)
+
+func runBackup(args []string) error {
+	game := findGame(args[0])
+	if game == nil {
+		return fmt.Errorf("game '%s' not found in config", args[0])
+	}
+
+	if len(args) == 1 {
+		backups, err := savesync.ListLocalBackups(game)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("[%s] No local backups.\n", game.Name)
+			return nil
+		}
+		fmt.Printf("[%s] Local backups:\n", game.Name)
+		for _, backup := range backups {
+			fmt.Printf("  %s  %s  %d file(s)\n", backup.ID, backup.Time.Local().Format("2006-01-02 15:04:05"), len(backup.Archived))
+		}
+		return nil
+	}
+
+	return savesync.RollbackLocalBackup(game, args[1])
+}
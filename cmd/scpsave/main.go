@@ -3,29 +3,58 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"scpsave/internal/config"
 	"scpsave/internal/filelog"
 	"scpsave/internal/gamewatcher"
+	"scpsave/internal/localdir"
 	"scpsave/internal/savesync"
 	"scpsave/internal/scp"
+	"scpsave/internal/storage"
 	"syscall"
+	"time"
 )
 
 var (
 	flagCreateSampleConfig = flag.Bool("c", false, "Create a sample config file and exit")
+	flagDryRun             = flag.Bool("dry-run", false, "Print what sync would upload, download and delete without changing anything")
+	flagRehash             = flag.Bool("rehash", false, "Ignore the hash cache and hash every local save file again")
+	flagConfig             = flag.String("config", "", "Path of the config file (env "+config.EnvConfigPath+")")
+	flagStateDir           = flag.String("state-dir", "", "Directory for working files and the log (env "+config.EnvStateDir+")")
+	flagPortable           = flag.Bool("portable", false, "Keep the config file, working files and the log in the current directory (env "+config.EnvPortable+"=1)")
 )
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() != 0 {
+	command := flag.Arg(0)
+	switch {
+	case *flagDryRun && flag.NArg() != 0:
+		flag.Usage()
+		os.Exit(1)
+	case flag.NArg() == 0:
+	case command == "restore" && (flag.NArg() == 2 || flag.NArg() == 3):
+	case command == "backup" && (flag.NArg() == 2 || flag.NArg() == 3):
+	case command == "config" && flag.Arg(1) == "validate":
+	default:
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	closelog, err := filelog.SetFileLog()
+	err := config.SetLocations(config.Locations{
+		ConfigPath: *flagConfig,
+		StateDir:   *flagStateDir,
+		Portable:   *flagPortable,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set config and state locations: %+v\n", err)
+	}
+
+	closelog, err := filelog.SetFileLog(config.LogFilePath())
 	if err != nil {
 		log.Fatalf("Failed to set file log: %+v\n", err)
 	}
@@ -38,25 +67,114 @@ func main() {
 		os.Exit(0)
 	}
 
+	if command == "config" {
+		// 설정에 문제가 있어도 모두 보여줘야 하므로 LoadConfig 보다 먼저 처리
+		if err := runValidate(flag.Args()[2:]); err != nil {
+			log.Fatalf("Invalid config: %+v\n", err)
+		}
+		return
+	}
+
+	log.Printf("Config file: %s, state directory: %s\n", config.ConfigPath, config.StateDir)
 	if err := config.LoadConfig(); err != nil {
 		log.Fatalf("Failed to load config: %+v\n", err)
 	}
 
-	scpclient, err := scp.NewClient(config.Value.ServerAddress, config.Value.Username, config.Value.PrivateKeyPath)
-	if err != nil {
-		log.Fatalf("Failed to create SCP client: %+v\n", err)
+	if command == "backup" {
+		if err := runBackup(flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to manage local backups: %+v\n", err)
+		}
+		return
 	}
-	defer scpclient.Close()
+
+	// 게임이 쓰는 원격에 처음 접근할 때 연결
+	backends := storage.NewBackends(func(name string) (storage.Backend, error) {
+		return newBackend(config.Value.Remote(name))
+	})
+	defer backends.Close()
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
-	ctx = scp.NewContextWithClient(ctx, scpclient)
+	ctx = storage.NewContextWithBackends(ctx, backends)
 
-	if err := savesync.SyncAll(ctx); err != nil {
+	// 해시를 다시 계산하는 것은 처음 한 번만
+	syncCtx := ctx
+	if *flagRehash {
+		syncCtx = savesync.NewContextWithRehash(syncCtx)
+	}
+
+	if command == "restore" {
+		if err := runRestore(syncCtx, flag.Args()[1:]); err != nil {
+			log.Fatalf("Failed to restore: %+v\n", err)
+		}
+		return
+	}
+
+	if *flagDryRun {
+		syncCtx = savesync.NewContextWithDryRun(syncCtx)
+	}
+	if err := savesync.SyncAll(syncCtx); err != nil {
 		log.Fatalf("Failed to sync saves: %+v\n", err)
 	}
+	if *flagDryRun {
+		return
+	}
 
 	gamewatcher.StartWatchGames(ctx)
 
 	log.Println("Exiting...")
 }
+
+func usage() {
+	out := flag.CommandLine.Output()
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(out, "Usage:\n")
+	fmt.Fprintf(out, "  %s [flags]                            Sync all games and watch running games\n", name)
+	fmt.Fprintf(out, "  %s [flags] restore <game>             List snapshots of a game\n", name)
+	fmt.Fprintf(out, "  %s [flags] restore <game> <snapshot>  Restore a snapshot to remote and local\n", name)
+	fmt.Fprintf(out, "  %s [flags] backup <game>              List local backups of a game\n", name)
+	fmt.Fprintf(out, "  %s [flags] backup <game> <backup>     Roll back local files to a local backup\n", name)
+	fmt.Fprintf(out, "  %s [flags] config validate [-connect] Check the config and list the files each game would sync\n", name)
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+func newBackend(remote *config.RemoteConfig) (storage.Backend, error) {
+	switch remote.Backend {
+	case config.BackendLocal:
+		return localdir.NewBackend(), nil
+	default:
+		keepAliveInterval := scp.DefaultKeepAliveInterval
+		if remote.KeepAliveInterval != 0 {
+			keepAliveInterval = time.Duration(remote.KeepAliveInterval) * time.Second
+		}
+		reconnectAttempts := scp.DefaultReconnectAttempts
+		if remote.ReconnectAttempts > 0 {
+			reconnectAttempts = remote.ReconnectAttempts
+		}
+		jumpHosts := make([]scp.JumpHost, 0, len(remote.JumpHosts))
+		for _, jump := range remote.JumpHosts {
+			jumpHosts = append(jumpHosts, scp.JumpHost{
+				ServerAddress:   jump.ServerAddress,
+				Username:        jump.Username,
+				AuthMethods:     jump.AuthMethods,
+				PrivateKeyPath:  jump.PrivateKeyPath,
+				CertificatePath: jump.CertificatePath,
+			})
+		}
+		return scp.NewClient(scp.Options{
+			ServerAddress:   remote.ServerAddress,
+			Username:        remote.Username,
+			AuthMethods:     remote.AuthMethods,
+			PrivateKeyPath:  remote.PrivateKeyPath,
+			CertificatePath: remote.CertificatePath,
+			KnownHostsPath:  remote.KnownHostsPath,
+			Protocol:        remote.RemoteProtocol,
+
+			KeepAliveInterval: keepAliveInterval,
+			ReconnectAttempts: reconnectAttempts,
+			TransferStateDir:  config.TransferStateDir(),
+			JumpHosts:         jumpHosts,
+		})
+	}
+}
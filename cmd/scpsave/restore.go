@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"scpsave/internal/config"
+	"scpsave/internal/savesync"
+	"strings"
+)
+
+func runRestore(ctx context.Context, args []string) error {
+	game := findGame(args[0])
+	if game == nil {
+		return fmt.Errorf("game '%s' not found in config", args[0])
+	}
+
+	if len(args) == 1 {
+		snapshots, err := savesync.ListSnapshots(ctx, game)
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf("[%s] No snapshots.\n", game.Name)
+			return nil
+		}
+		fmt.Printf("[%s] Snapshots:\n", game.Name)
+		for _, snapshot := range snapshots {
+			current := " "
+			if snapshot.Current {
+				current = "*"
+			}
+			fmt.Printf("%s %s  %s  %s\n", current, snapshot.ID, snapshot.Time.Local().Format("2006-01-02 15:04:05"), snapshot.Hostname)
+		}
+		return nil
+	}
+
+	return savesync.RestoreSnapshot(ctx, game, args[1])
+}
+
+func findGame(name string) *config.GameConfig {
+	for _, game := range config.Value.Games {
+		if strings.EqualFold(game.Name, name) || strings.EqualFold(game.AltName, name) {
+			return game
+		}
+	}
+	return nil
+}
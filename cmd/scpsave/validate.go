@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"scpsave/internal/config"
+	"sort"
+)
+
+// runValidate 설정의 모든 문제를 위치와 함께 출력하고 게임마다 동기화할 파일을 보여줌
+// -connect 를 주면 게임이 쓰는 원격에 접속해서 remote_root 를 확인
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	connect := flags.Bool("connect", false, "Connect to each remote used by games and check remote_root")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	result, err := config.Validate(config.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	for _, game := range result.Games {
+		files, ok := result.Files[game.Name]
+		if !ok {
+			continue
+		}
+		fmt.Printf("[%s] %d file(s) to sync in %s\n", game.Name, len(files), game.LocalDir)
+		for _, relPath := range files {
+			fmt.Printf("  %s\n", relPath)
+		}
+	}
+
+	errorCount := 0
+	for _, problem := range result.Problems {
+		fmt.Printf("%s:%s\n", config.ConfigPath, problem)
+		if !problem.Warning {
+			errorCount++
+		}
+	}
+
+	if *connect {
+		if errorCount > 0 {
+			fmt.Println("Skipping connectivity check because of errors.")
+		} else {
+			errorCount += checkConnectivity()
+		}
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("%d error(s) in %s", errorCount, config.ConfigPath)
+	}
+	fmt.Printf("%s is valid.\n", config.ConfigPath)
+	return nil
+}
+
+// checkConnectivity 게임이 쓰는 원격마다 접속해서 remote_root 를 읽어 봄, 실패한 원격 수를 반환
+func checkConnectivity() int {
+	if err := config.LoadConfig(); err != nil {
+		fmt.Printf("Failed to load config: %+v\n", err)
+		return 1
+	}
+
+	used := make(map[string]struct{})
+	for _, game := range config.Value.Games {
+		used[game.Remote] = struct{}{}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, name := range names {
+		label := "default"
+		if name != "" {
+			label = "'" + name + "'"
+		}
+		if err := checkRemote(config.Value.Remote(name)); err != nil {
+			fmt.Printf("remote %s: error: %+v\n", label, err)
+			failed++
+			continue
+		}
+		fmt.Printf("remote %s: ok\n", label)
+	}
+	return failed
+}
+
+func checkRemote(remote *config.RemoteConfig) error {
+	backend, err := newBackend(remote)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	info, err := backend.StatRemoteFile(remote.RemoteRoot)
+	if err != nil {
+		return fmt.Errorf("failed to check remote_root %s: %w", remote.RemoteRoot, err)
+	}
+	if !info.IsDir {
+		return errors.New("remote_root " + remote.RemoteRoot + " is not a directory")
+	}
+	return nil
+}
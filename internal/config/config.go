@@ -8,32 +8,80 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"scpsave/internal/filelist"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// RemoteConfig 원격 저장소 하나의 접속 설정
+type RemoteConfig struct {
+	Backend         string      `yaml:"backend,omitempty"`
+	ServerAddress   string      `yaml:"server_address,omitempty"`
+	Username        string      `yaml:"username,omitempty"`
+	AuthMethods     []string    `yaml:"auth_methods,omitempty"`
+	PrivateKeyPath  string      `yaml:"private_key_path,omitempty"`
+	CertificatePath string      `yaml:"certificate_path,omitempty"`
+	KnownHostsPath  string      `yaml:"known_hosts_path,omitempty"`
+	RemoteProtocol  string      `yaml:"remote_protocol,omitempty"`
+	RemoteRoot      string      `yaml:"remote_root"`
+	JumpHosts       []*JumpHost `yaml:"jump_hosts,omitempty"`
+
+	KeepAliveInterval int `yaml:"keepalive_interval,omitempty"` // 초 단위, 음수이면 사용 안함
+	ReconnectAttempts int `yaml:"reconnect_attempts,omitempty"`
+}
+
+// Config 최상위의 원격 설정은 remote 를 지정하지 않은 게임이 쓰는 기본 원격
 type Config struct {
-	ServerAddress  string        `yaml:"server_address"`
-	Username       string        `yaml:"username"`
-	PrivateKeyPath string        `yaml:"private_key_path"`
-	RemoteRoot     string        `yaml:"remote_root"`
-	Games          []*GameConfig `yaml:"games"`
+	RemoteConfig `yaml:",inline"`
+	Remotes      map[string]*RemoteConfig `yaml:"remotes,omitempty"`
+	Games        []*GameConfig            `yaml:"games"`
+
+	LocalBackupCount *int `yaml:"local_backup_count,omitempty"` // 게임마다 남길 로컬 백업 수, 0 이나 음수이면 사용 안함
+	LockTimeout      int  `yaml:"lock_timeout,omitempty"`       // 초 단위, 갱신되지 않은 원격 잠금을 버려진 것으로 보는 시간
+	HistoryCount     int  `yaml:"history_count,omitempty"`      // 게임마다 원격에 남길 세대 수
 
 	WatchTargetCount int `yaml:"-"`
 }
 
+// JumpHost 서버에 접속하기 위해 차례로 거쳐가는 호스트
+// username, private_key_path 를 생략하면 서버의 설정을 사용
+type JumpHost struct {
+	ServerAddress   string   `yaml:"server_address,omitempty"`
+	Username        string   `yaml:"username,omitempty"`
+	AuthMethods     []string `yaml:"auth_methods,omitempty"`
+	PrivateKeyPath  string   `yaml:"private_key_path,omitempty"`
+	CertificatePath string   `yaml:"certificate_path,omitempty"`
+}
+
 type GameConfig struct {
 	Name         string   `yaml:"name"`
 	LocalDir     string   `yaml:"local_dir"`
-	FilePatterns []string `yaml:"file_patterns"`
+	FilePatterns []string `yaml:"file_patterns,omitempty"`
+	Include      []string `yaml:"include,omitempty"` // gitignore 형식의 glob, '!' 로 시작하면 제외
+	Exclude      []string `yaml:"exclude,omitempty"` // gitignore 형식의 glob
 	ProgramName  string   `yaml:"program_name"`
+	Remote       string   `yaml:"remote,omitempty"` // remotes 의 이름, 비어 있으면 기본 원격
+
+	ParallelTransfers int `yaml:"parallel_transfers,omitempty"`
+	HistoryCount      int `yaml:"history_count,omitempty"` // 원격에 남길 세대 수, 없으면 최상위 history_count
 
-	RemoteRoot string           `yaml:"-"`
-	AltName    string           `yaml:"-"`
-	FileRegExp []*regexp.Regexp `yaml:"-"`
+	RemoteRoot  string            `yaml:"-"`
+	AltName     string            `yaml:"-"`
+	FileMatcher *filelist.Matcher `yaml:"-"`
 }
 
+const (
+	BackendSCP   = "scp"
+	BackendLocal = "local"
+)
+
+const (
+	DefaultLocalBackupCount = 10
+	DefaultLockTimeout      = 600
+	DefaultHistoryCount     = 20
+)
+
 var (
 	Value *Config
 
@@ -41,12 +89,12 @@ var (
 )
 
 func LoadConfig() error {
-	bt, err := os.ReadFile("./config.yaml")
+	bt, err := os.ReadFile(ConfigPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			log.Println(`config.yaml file not found.`)
-			log.Println(`Run "scpsave.exe -c" to create a "config.sample.yaml" file.`)
-			log.Println(`After editing it, rename it to "config.yaml" and run the program again.`)
+			log.Printf("%s file not found.\n", ConfigPath)
+			log.Printf(`Run "scpsave.exe -c" to create a "%s" file.`+"\n", SampleConfigPath())
+			log.Printf(`After editing it, rename it to "%s" and run the program again.`+"\n", filepath.Base(ConfigPath))
 		}
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -55,57 +103,180 @@ func LoadConfig() error {
 	if err := yaml.Unmarshal(bt, &config); err != nil {
 		return fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-	if len(config.Games) == 0 {
-		return errors.New("empty games config")
+	if err := config.expandPaths(); err != nil {
+		return err
+	}
+	if errs := config.checkRules(); len(errs) > 0 {
+		return errs[0]
+	}
+	if err := config.RemoteConfig.setDefaults(); err != nil {
+		return err
+	}
+	for name, remote := range config.Remotes {
+		if err := remote.setDefaults(); err != nil {
+			return fmt.Errorf("remote '%s': %w", name, err)
+		}
+	}
+	if config.HistoryCount <= 0 {
+		config.HistoryCount = DefaultHistoryCount
+	}
+	if config.LockTimeout <= 0 {
+		config.LockTimeout = DefaultLockTimeout
 	}
-
-	testAltNames := make(map[string]struct{})
 	for _, game := range config.Games {
-		game.RemoteRoot = config.RemoteRoot
+		game.RemoteRoot = config.Remote(game.Remote).RemoteRoot
+		if game.ParallelTransfers <= 0 {
+			game.ParallelTransfers = 1
+		}
+		if game.HistoryCount <= 0 {
+			game.HistoryCount = config.HistoryCount
+		}
 		game.ProgramName = strings.ToLower(game.ProgramName)
 		if game.ProgramName != "" {
 			config.WatchTargetCount++
 		}
 
 		game.AltName = ReAltName.ReplaceAllString(game.Name, "")
-		if game.AltName == "" {
-			return fmt.Errorf("game '%s' has an invalid name that results in an empty AltName", game.Name)
-		}
-		if _, exists := testAltNames[game.AltName]; exists {
-			return fmt.Errorf("game '%s' has a duplicate AltName '%s'", game.Name, game.AltName)
-		}
-		testAltNames[game.AltName] = struct{}{}
 
+		var fileRegExp []*regexp.Regexp
 		for _, pattern := range game.FilePatterns {
 			re, err := regexp.Compile(strings.ToLower(pattern))
 			if err != nil {
 				return fmt.Errorf("failed to compile regex pattern '%s' for game '%s': %w", pattern, game.Name, err)
 			}
-			game.FileRegExp = append(game.FileRegExp, re)
+			fileRegExp = append(fileRegExp, re)
+		}
+		game.FileMatcher, err = filelist.NewMatcher(fileRegExp, game.Include, game.Exclude)
+		if err != nil {
+			return fmt.Errorf("game '%s': %w", game.Name, err)
 		}
 	}
 	Value = &config
 	return nil
 }
 
+// expandPaths 경로 설정의 ~, 환경 변수, 내장 변수를 바꿈
+func (c *Config) expandPaths() error {
+	for _, v := range c.pathValues() {
+		if err := expandPaths(v.Value); err != nil {
+			return &ruleError{Path: v.Path, Owner: v.Owner, Err: err}
+		}
+	}
+	return nil
+}
+
+// LocalBackups 게임마다 남길 로컬 백업 수, 설정에 없으면 기본값
+// 0 은 남기지 않는다는 뜻이라 포인터로 생략한 것과 구분함
+func (c *Config) LocalBackups() int {
+	if c.LocalBackupCount == nil {
+		return DefaultLocalBackupCount
+	}
+	return *c.LocalBackupCount
+}
+
+// Remote 이름에 해당하는 원격 설정, 빈 이름은 기본 원격
+func (c *Config) Remote(name string) *RemoteConfig {
+	if name == "" {
+		return &c.RemoteConfig
+	}
+	return c.Remotes[name]
+}
+
+// setDefaults 규칙을 통과한 설정의 빠진 값을 채움, 점프 호스트는 생략한 값을 서버에서 물려받음
+func (r *RemoteConfig) setDefaults() error {
+	if r.Backend == BackendLocal {
+		return nil
+	}
+	r.AuthMethods = r.authMethods()
+	for _, jump := range r.JumpHosts {
+		if jump == nil {
+			continue
+		}
+		if jump.Username == "" {
+			jump.Username = r.Username
+		}
+		if jump.PrivateKeyPath == "" {
+			jump.PrivateKeyPath = r.PrivateKeyPath
+			if jump.CertificatePath == "" {
+				jump.CertificatePath = r.CertificatePath
+			}
+		}
+		if len(jump.AuthMethods) == 0 {
+			jump.AuthMethods = r.AuthMethods
+		}
+	}
+	if r.KnownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get user home directory for known_hosts: %w", err)
+		}
+		r.KnownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	return nil
+}
+
+func checkAuthMethods(methods []string, privateKeyPath string) error {
+	testAuthMethods := make(map[string]struct{})
+	for _, method := range methods {
+		switch method {
+		case "agent":
+		case "publickey":
+			if privateKeyPath == "" {
+				return errors.New("auth method 'publickey' requires private_key_path")
+			}
+		default:
+			return fmt.Errorf("invalid auth method '%s', must be 'agent' or 'publickey'", method)
+		}
+		if _, exists := testAuthMethods[method]; exists {
+			return fmt.Errorf("duplicate auth method '%s'", method)
+		}
+		testAuthMethods[method] = struct{}{}
+	}
+	return nil
+}
+
 func MakeSampleConfig() error {
+	localBackupCount := DefaultLocalBackupCount
 	config := Config{
-		ServerAddress:  "example.com:22",
-		Username:       "user",
-		PrivateKeyPath: `C:\Users\user\.ssh\id_rsa`,
-		RemoteRoot:     "/remote/path",
+		RemoteConfig: RemoteConfig{
+			Backend:        BackendSCP,
+			ServerAddress:  "example.com:22",
+			Username:       "user",
+			AuthMethods:    []string{"agent", "publickey"},
+			PrivateKeyPath: `C:\Users\user\.ssh\id_rsa`,
+			KnownHostsPath: `C:\Users\user\.ssh\known_hosts`,
+			RemoteProtocol: "scp",
+			RemoteRoot:     "/remote/path",
+			JumpHosts: []*JumpHost{
+				{
+					ServerAddress: "bastion.example.com:22",
+					Username:      "jumpuser",
+				},
+			},
+		},
+		Remotes: map[string]*RemoteConfig{
+			"nas": {
+				Backend:    BackendLocal,
+				RemoteRoot: `\\nas\saves`,
+			},
+		},
+		LocalBackupCount: &localBackupCount,
 		Games: []*GameConfig{
 			{
-				Name:         "Game1",
-				LocalDir:     `C:\Users\user\Games\Game1`,
-				FilePatterns: []string{`some.+\\.+\.save`, `.+\.dat`},
-				ProgramName:  "game1.exe",
+				Name:        "Game1",
+				LocalDir:    `{saved_games}\Game1`,
+				Include:     []string{`some*/**/*.save`, `*.dat`},
+				Exclude:     []string{`**/backup/`, `*.tmp`},
+				ProgramName: "game1.exe",
+
+				ParallelTransfers: 4,
 			},
 			{
 				Name:         "Game2",
 				LocalDir:     `C:\Users\user\Games\Game2`,
 				FilePatterns: []string{`.+\.sav`, `.+\.dat`},
 				ProgramName:  `C:\Game\Folder\game2.exe`,
+				Remote:       "nas",
 			},
 		},
 	}
@@ -113,40 +284,136 @@ func MakeSampleConfig() error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal sample config: %w", err)
 	}
-	if err := os.WriteFile("./config.sample.yaml", bt, 0644); err != nil {
+	if err := os.MkdirAll(filepath.Dir(SampleConfigPath()), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(SampleConfigPath(), bt, 0644); err != nil {
 		return fmt.Errorf("failed to write sample config file: %w", err)
 	}
+	log.Printf("Created sample config file %s\n", SampleConfigPath())
 	return nil
 }
 
+// SampleConfigPath 설정 파일과 같은 디렉터리의 config.sample.yaml
+func SampleConfigPath() string {
+	return filepath.Join(filepath.Dir(ConfigPath), "config.sample.yaml")
+}
+
+func TransferStateDir() string {
+	return filepath.Join(WorkingDir(), ".transfers")
+}
+
+// RemoteLabel 로그에 쓸 원격 이름
+func (g *GameConfig) RemoteLabel() string {
+	if g.Remote == "" {
+		return "default"
+	}
+	return "'" + g.Remote + "'"
+}
+
 func (g *GameConfig) BaseMetaFilePath() string {
-	return filepath.Join(".", "working", g.AltName, "base.yaml")
+	return filepath.Join(WorkingDir(), g.AltName, "base.yaml")
+}
+
+func (g *GameConfig) HashCacheFilePath() string {
+	return filepath.Join(WorkingDir(), g.AltName, "hashcache.yaml")
 }
 
 func (g *GameConfig) RemoteMetaFileLocalPath() string {
-	return filepath.Join(".", "working", g.AltName, "remote.yaml")
+	return filepath.Join(WorkingDir(), g.AltName, "remote.yaml")
 }
 
 func (g *GameConfig) LocalFilePath(relPath string) string {
 	return filepath.Join(g.LocalDir, relPath)
 }
 
+// LocalFileDownloadPath 받은 파일을 로컬에 반영하기 전에 두는 곳
+// 저장 파일 이름이 동기화 상태 파일과 겹치지 않도록 download/ 아래에 둠
 func (g *GameConfig) LocalFileDownloadPath(relPath string) string {
-	return filepath.Join(".", "working", g.AltName, relPath)
+	return filepath.Join(WorkingDir(), g.AltName, "download", relPath)
+}
+
+// JournalFilePath 진행 중인 동기화의 단계를 기록하는 파일, 끝까지 마치면 지움
+func (g *GameConfig) JournalFilePath() string {
+	return filepath.Join(WorkingDir(), g.AltName, "journal.yaml")
+}
+
+func (g *GameConfig) GenerationFileLocalPath() string {
+	return filepath.Join(WorkingDir(), g.AltName, "generation")
+}
+
+// RemoteGenerationPath 현재 세대(스냅샷) ID 를 담은 파일, 이 파일을 바꿔서 업로드를 한 번에 반영
+func (g *GameConfig) RemoteGenerationPath() string {
+	return path.Join(g.RemoteRoot, "meta", g.AltName, "current")
+}
+
+func (g *GameConfig) RemoteGenerationUploadPath() string {
+	return path.Join(g.RemoteRoot, "meta", g.AltName, "current.temp")
 }
 
+// RemoteMetaFileRemotePath 세대를 쓰기 전의 메타데이터
 func (g *GameConfig) RemoteMetaFileRemotePath() string {
 	return path.Join(g.RemoteRoot, "meta", g.AltName, "remote.yaml")
 }
 
-func (g *GameConfig) RemoteMetaFileUploadPath() string {
-	return path.Join(g.RemoteRoot, "meta", g.AltName, "remote.temp.yaml")
+func (g *GameConfig) RemoteUploadDir() string {
+	return path.Join(g.RemoteRoot, "upload", g.AltName)
 }
 
 func (g *GameConfig) RemoteFileUploadPath(relPath string) string {
-	return path.Join(g.RemoteRoot, "upload", g.AltName, relPath)
+	return path.Join(g.RemoteUploadDir(), relPath)
+}
+
+// RemoteSaveDir 세대를 쓰기 전의 저장 파일 디렉터리
+func (g *GameConfig) RemoteSaveDir() string {
+	return path.Join(g.RemoteRoot, "save", g.AltName)
 }
 
 func (g *GameConfig) RemoteFilePath(relPath string) string {
-	return path.Join(g.RemoteRoot, "save", g.AltName, relPath)
+	return path.Join(g.RemoteSaveDir(), relPath)
+}
+
+func (g *GameConfig) SnapshotMetaFileLocalPath() string {
+	return filepath.Join(WorkingDir(), g.AltName, "snapshot.yaml")
+}
+
+func (g *GameConfig) RemoteHistoryDir() string {
+	return path.Join(g.RemoteRoot, "history", g.AltName)
+}
+
+func (g *GameConfig) RemoteSnapshotMetaPath(snapshot string) string {
+	return path.Join(g.RemoteHistoryDir(), snapshot, "remote.yaml")
+}
+
+func (g *GameConfig) RemoteSnapshotFilePath(snapshot, relPath string) string {
+	return path.Join(g.RemoteHistoryDir(), snapshot, "save", relPath)
+}
+
+func (g *GameConfig) SnapshotMetaCachePath(snapshot string) string {
+	return filepath.Join(WorkingDir(), g.AltName, "generations", snapshot+".yaml")
+}
+
+func (g *GameConfig) RemoteBlobDir() string {
+	return path.Join(g.RemoteRoot, "blobs", g.AltName)
+}
+
+// RemoteBlobPath 내용의 SHA-512 로 찾는 저장 파일, 같은 내용은 한 번만 저장
+func (g *GameConfig) RemoteBlobPath(hash string) string {
+	return path.Join(g.RemoteBlobDir(), hash[:2], hash)
+}
+
+func (g *GameConfig) LocalBackupDir() string {
+	return filepath.Join(WorkingDir(), g.AltName, "backup")
+}
+
+func (g *GameConfig) LocalBackupMetaPath(backup string) string {
+	return filepath.Join(g.LocalBackupDir(), backup, "backup.yaml")
+}
+
+func (g *GameConfig) LocalBackupFilePath(backup, relPath string) string {
+	return filepath.Join(g.LocalBackupDir(), backup, "files", relPath)
+}
+
+func (g *GameConfig) RemoteLockPath() string {
+	return path.Join(g.RemoteRoot, "lock", g.AltName+".lock")
 }
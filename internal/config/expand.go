@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 경로 설정에 쓸 수 있는 변수
+//   - ~ : 맨 앞에 있을 때 홈 디렉터리
+//   - $NAME, ${NAME}, %NAME% : 환경 변수
+//   - {home}, {documents}, {saved_games}, {hostname} : 내장 변수
+//
+// 없는 변수는 빈 문자열로 바꾸지 않고 오류로 처리함
+
+var rePathVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)|%([A-Za-z_][A-Za-z0-9_()]*)%|\{([a-z_]+)\}`)
+
+var builtinPathVariables = map[string]func() (string, error){
+	"home":        os.UserHomeDir,
+	"documents":   documentsDir,
+	"saved_games": savedGamesDir,
+	"hostname":    os.Hostname,
+}
+
+// ExpandPath p 의 변수를 바꿈
+func ExpandPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory for '%s': %w", p, err)
+		}
+		p = home + p[1:]
+	}
+
+	var expandErr error
+	expanded := rePathVariable.ReplaceAllStringFunc(p, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+		m := rePathVariable.FindStringSubmatch(match)
+		if name := m[4]; name != "" {
+			value, ok := builtinPathVariables[name]
+			if !ok {
+				expandErr = fmt.Errorf("unknown variable '%s' in path '%s'", match, p)
+				return match
+			}
+			v, err := value()
+			if err != nil {
+				expandErr = fmt.Errorf("failed to get '%s' for path '%s': %w", match, p, err)
+				return match
+			}
+			return v
+		}
+
+		name := m[1] + m[2] + m[3]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			expandErr = fmt.Errorf("unknown environment variable '%s' in path '%s'", match, p)
+			return match
+		}
+		return v
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// expandPaths 여러 경로 설정을 한 번에 바꿈, 비어 있는 값은 그대로 둠
+func expandPaths(paths ...*string) error {
+	for _, p := range paths {
+		if *p == "" {
+			continue
+		}
+		expanded, err := ExpandPath(*p)
+		if err != nil {
+			return err
+		}
+		*p = filepath.Clean(expanded)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip(err)
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	t.Setenv("SCPSAVE_TEST_DIR", "/saves")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", home},
+		{"~/game", home + "/game"},
+		{"a~/game", "a~/game"},
+		{"$SCPSAVE_TEST_DIR/game", "/saves/game"},
+		{"${SCPSAVE_TEST_DIR}game", "/savesgame"},
+		{"%SCPSAVE_TEST_DIR%/game", "/saves/game"},
+		{"{home}/game", home + "/game"},
+		{"/saves/{hostname}", "/saves/" + hostname},
+		{"/plain/path", "/plain/path"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.path)
+		if err != nil {
+			t.Errorf("ExpandPath(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPathUnknownVariable(t *testing.T) {
+	for _, p := range []string{"$SCPSAVE_TEST_UNSET/game", "%SCPSAVE_TEST_UNSET%", "{unknown}/game"} {
+		if got, err := ExpandPath(p); err == nil {
+			t.Errorf("ExpandPath(%q) = %q, want an error", p, got)
+		}
+	}
+}
+
+func TestExpandPathsCleans(t *testing.T) {
+	t.Setenv("SCPSAVE_TEST_DIR", "/saves/")
+	p, empty := "$SCPSAVE_TEST_DIR/./game/", ""
+	if err := expandPaths(&p, &empty); err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Clean("/saves/game"); p != want {
+		t.Errorf("expanded path = %q, want %q", p, want)
+	}
+	if empty != "" {
+		t.Errorf("empty path was changed to %q", empty)
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// documentsDir $XDG_DOCUMENTS_DIR, 없으면 ~/Documents
+func documentsDir() (string, error) {
+	if dir := os.Getenv("XDG_DOCUMENTS_DIR"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Documents"), nil
+}
+
+// savedGamesDir Windows 의 Saved Games 에 해당하는 곳이 없으므로 $XDG_DATA_HOME, 없으면 ~/.local/share
+func savedGamesDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package config
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func documentsDir() (string, error) {
+	return windows.KnownFolderPath(windows.FOLDERID_Documents, 0)
+}
+
+func savedGamesDir() (string, error) {
+	return windows.KnownFolderPath(windows.FOLDERID_SavedGames, 0)
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// 설정 파일과 상태(working/, 로그) 위치를 정하는 환경 변수
+const (
+	EnvConfigPath = "SCPSAVE_CONFIG"
+	EnvStateDir   = "SCPSAVE_STATE_DIR"
+	EnvPortable   = "SCPSAVE_PORTABLE"
+)
+
+const appName = "scpsave"
+
+// 포터블 모드의 기본값, 실행한 디렉터리에 설정과 상태를 모두 둠
+var (
+	ConfigPath = filepath.Join(".", "config.yaml")
+	StateDir   = "."
+)
+
+// Locations 명령줄에서 받은 위치, 비어 있으면 환경 변수나 기본값을 씀
+type Locations struct {
+	ConfigPath string
+	StateDir   string
+	Portable   bool
+}
+
+// SetLocations 설정 파일과 상태 디렉터리 위치를 정함
+// 우선순위는 명령줄, 환경 변수, 기본값 순서
+// 따로 지정하지 않았고 현재 디렉터리에 config.yaml 이 있으면 예전처럼 포터블 모드로 동작
+func SetLocations(loc Locations) error {
+	configPath := firstNonEmpty(loc.ConfigPath, os.Getenv(EnvConfigPath))
+	stateDir := firstNonEmpty(loc.StateDir, os.Getenv(EnvStateDir))
+	portable := loc.Portable || os.Getenv(EnvPortable) == "1"
+	if !portable && configPath == "" && stateDir == "" {
+		if _, err := os.Stat(filepath.Join(".", "config.yaml")); err == nil {
+			portable = true
+		}
+	}
+
+	if configPath == "" {
+		if portable {
+			configPath = filepath.Join(".", "config.yaml")
+		} else {
+			dir, err := os.UserConfigDir()
+			if err != nil {
+				return fmt.Errorf("failed to get user config directory: %w", err)
+			}
+			configPath = filepath.Join(dir, appName, "config.yaml")
+		}
+	}
+	if stateDir == "" {
+		if portable {
+			stateDir = "."
+		} else {
+			dir, err := userStateDir()
+			if err != nil {
+				return fmt.Errorf("failed to get user state directory: %w", err)
+			}
+			stateDir = filepath.Join(dir, appName)
+		}
+	}
+
+	// 나중에 작업 디렉터리가 바뀌어도 같은 곳을 쓰도록 절대 경로로 바꿈
+	var err error
+	if ConfigPath, err = filepath.Abs(configPath); err != nil {
+		return fmt.Errorf("failed to resolve config path %s: %w", configPath, err)
+	}
+	if StateDir, err = filepath.Abs(stateDir); err != nil {
+		return fmt.Errorf("failed to resolve state directory %s: %w", stateDir, err)
+	}
+	if err := os.MkdirAll(StateDir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory %s: %w", StateDir, err)
+	}
+	return nil
+}
+
+// userStateDir 플랫폼의 관례에 따른 상태 디렉터리
+//   - Linux 등: $XDG_STATE_HOME, 없으면 ~/.local/state
+//   - Windows: %LocalAppData%
+//   - macOS: ~/Library/Application Support
+func userStateDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return dir, nil
+		}
+		return os.UserConfigDir()
+	case "darwin", "ios":
+		return os.UserConfigDir()
+	}
+
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// WorkingDir 게임마다의 상태 파일을 두는 디렉터리
+func WorkingDir() string {
+	return filepath.Join(StateDir, "working")
+}
+
+func LogFilePath() string {
+	return filepath.Join(StateDir, "scpsave.log")
+}
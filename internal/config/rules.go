@@ -0,0 +1,279 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
+	"regexp"
+	"scpsave/internal/filelist"
+	"sort"
+	"strings"
+)
+
+// 설정 규칙, LoadConfig 는 첫 문제를 오류로 돌려주고 Validate 는 모든 문제를 위치와 함께 알림
+// 경로 설정의 변수는 검사하기 전에 바꿔 둬야 함
+
+// ruleError 설정 규칙을 어긴 곳
+// Path 는 최상위부터 문제가 있는 값까지의 매핑 키(string)와 목록 순번(int)
+// Owner 는 메시지 앞에 붙일 원격이나 게임, 기본 원격이면 비어 있음
+type ruleError struct {
+	Path  []any
+	Owner string
+	Err   error
+}
+
+func (e *ruleError) Error() string {
+	if e.Owner == "" {
+		return e.Err.Error()
+	}
+	return e.Owner + ": " + e.Err.Error()
+}
+
+func (e *ruleError) Unwrap() error {
+	return e.Err
+}
+
+type ruleErrors []*ruleError
+
+func (errs *ruleErrors) addf(owner string, p []any, format string, args ...any) {
+	*errs = append(*errs, &ruleError{Path: p, Owner: owner, Err: fmt.Errorf(format, args...)})
+}
+
+// at base 뒤에 elems 를 붙인 새 위치
+func at(base []any, elems ...any) []any {
+	p := make([]any, 0, len(base)+len(elems))
+	p = append(p, base...)
+	return append(p, elems...)
+}
+
+func remoteOwner(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "remote '" + name + "'"
+}
+
+func gameOwner(g *GameConfig) string {
+	return "game '" + g.Name + "'"
+}
+
+// pathValue 변수를 바꿀 경로 설정
+type pathValue struct {
+	Path  []any
+	Owner string
+	Value *string
+}
+
+// pathValues 변수를 바꿀 경로 설정, scp 의 remote_root 는 서버의 경로이므로 빠짐
+func (c *Config) pathValues() []pathValue {
+	values := c.RemoteConfig.pathValues("", nil)
+	for _, name := range c.remoteNames() {
+		if remote := c.Remotes[name]; remote != nil {
+			values = append(values, remote.pathValues(remoteOwner(name), at(nil, "remotes", name))...)
+		}
+	}
+	for i, game := range c.Games {
+		if game == nil {
+			continue
+		}
+		p := at(nil, "games", i)
+		values = append(values,
+			pathValue{at(p, "local_dir"), gameOwner(game), &game.LocalDir},
+			pathValue{at(p, "program_name"), gameOwner(game), &game.ProgramName},
+		)
+	}
+	return values
+}
+
+func (r *RemoteConfig) pathValues(owner string, p []any) []pathValue {
+	values := []pathValue{
+		{at(p, "private_key_path"), owner, &r.PrivateKeyPath},
+		{at(p, "certificate_path"), owner, &r.CertificatePath},
+		{at(p, "known_hosts_path"), owner, &r.KnownHostsPath},
+	}
+	if r.Backend == BackendLocal {
+		values = append(values, pathValue{at(p, "remote_root"), owner, &r.RemoteRoot})
+	}
+	for i, jump := range r.JumpHosts {
+		if jump == nil {
+			continue
+		}
+		jp := at(p, "jump_hosts", i)
+		values = append(values,
+			pathValue{at(jp, "private_key_path"), owner, &jump.PrivateKeyPath},
+			pathValue{at(jp, "certificate_path"), owner, &jump.CertificatePath},
+		)
+	}
+	return values
+}
+
+func (c *Config) remoteNames() []string {
+	names := make([]string, 0, len(c.Remotes))
+	for name := range c.Remotes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkRules 설정 전체의 규칙, 기본 원격은 쓰는 게임이 있을 때만 검사
+func (c *Config) checkRules() ruleErrors {
+	var errs ruleErrors
+	if len(c.Games) == 0 {
+		errs.addf("", at(nil, "games"), "no games")
+	}
+
+	used := make(map[string]bool)
+	altNames := make(map[string]string)
+	for i, game := range c.Games {
+		p := at(nil, "games", i)
+		if game == nil {
+			errs.addf("", p, "empty game")
+			continue
+		}
+		if _, exists := c.Remotes[game.Remote]; game.Remote != "" && !exists {
+			errs.addf(gameOwner(game), at(p, "remote"), "unknown remote '%s'", game.Remote)
+		} else {
+			used[game.Remote] = true
+		}
+		game.checkRules(&errs, p)
+
+		altName := ReAltName.ReplaceAllString(game.Name, "")
+		if altName == "" {
+			continue
+		}
+		if other, exists := altNames[altName]; exists {
+			errs.addf(gameOwner(game), at(p, "name"), "same AltName '%s' as game '%s'", altName, other)
+		} else {
+			altNames[altName] = game.Name
+		}
+	}
+
+	// 모든 게임이 이름 있는 원격을 쓰면 최상위 설정은 비어 있어도 됨
+	if used[""] {
+		c.RemoteConfig.checkRules(&errs, "", nil)
+	}
+	for _, name := range c.remoteNames() {
+		p := at(nil, "remotes", name)
+		if name == "" {
+			errs.addf("", p, "remote with an empty name")
+			continue
+		}
+		if c.Remotes[name] == nil {
+			errs.addf("", p, "remote '%s' is empty", name)
+			continue
+		}
+		c.Remotes[name].checkRules(&errs, remoteOwner(name), p)
+	}
+	return errs
+}
+
+func (r *RemoteConfig) checkRules(errs *ruleErrors, owner string, p []any) {
+	switch r.Backend {
+	case "", BackendSCP:
+	case BackendLocal:
+		if r.RemoteRoot == "" {
+			errs.addf(owner, p, "remote_root is required")
+		} else if !filepath.IsAbs(r.RemoteRoot) {
+			errs.addf(owner, at(p, "remote_root"), "remote_root '%s' must be an absolute path for the local backend", r.RemoteRoot)
+		}
+		return
+	default:
+		errs.addf(owner, at(p, "backend"), "invalid backend '%s', must be '%s' or '%s'", r.Backend, BackendSCP, BackendLocal)
+		return
+	}
+
+	// 상대 경로는 서버의 로그인 디렉터리에 따라 가리키는 곳이 달라짐
+	if r.RemoteRoot == "" {
+		errs.addf(owner, p, "remote_root is required")
+	} else if !path.IsAbs(r.RemoteRoot) {
+		errs.addf(owner, at(p, "remote_root"), "remote_root '%s' must be an absolute path on the server", r.RemoteRoot)
+	}
+	if r.ServerAddress == "" {
+		errs.addf(owner, p, "server_address is required")
+	} else if _, _, err := net.SplitHostPort(r.ServerAddress); err != nil {
+		errs.addf(owner, at(p, "server_address"), "invalid server_address '%s', must be host:port", r.ServerAddress)
+	}
+	if r.Username == "" {
+		errs.addf(owner, p, "username is required")
+	}
+	switch r.RemoteProtocol {
+	case "", "scp", "sftp":
+	default:
+		errs.addf(owner, at(p, "remote_protocol"), "invalid remote_protocol '%s', must be 'scp' or 'sftp'", r.RemoteProtocol)
+	}
+
+	methods := r.authMethods()
+	if err := checkAuthMethods(methods, r.PrivateKeyPath); err != nil {
+		errs.addf(owner, at(p, "auth_methods"), "%v", err)
+	}
+	for i, jump := range r.JumpHosts {
+		jp := at(p, "jump_hosts", i)
+		if jump == nil {
+			errs.addf(owner, jp, "empty jump host")
+			continue
+		}
+		if jump.ServerAddress == "" {
+			errs.addf(owner, jp, "jump host #%d has no server_address", i+1)
+		} else if _, _, err := net.SplitHostPort(jump.ServerAddress); err != nil {
+			errs.addf(owner, at(jp, "server_address"), "invalid jump host server_address '%s', must be host:port", jump.ServerAddress)
+		}
+		if jump.PrivateKeyPath == "" && len(jump.AuthMethods) == 0 {
+			// 서버의 설정을 그대로 물려받으므로 이미 검사함
+			continue
+		}
+		jumpMethods, keyPath := jump.AuthMethods, jump.PrivateKeyPath
+		if len(jumpMethods) == 0 {
+			jumpMethods = methods
+		}
+		if keyPath == "" {
+			keyPath = r.PrivateKeyPath
+		}
+		if err := checkAuthMethods(jumpMethods, keyPath); err != nil {
+			errs.addf(owner, at(jp, "auth_methods"), "jump host '%s': %v", jump.ServerAddress, err)
+		}
+	}
+}
+
+// authMethods 생략하면 publickey
+func (r *RemoteConfig) authMethods() []string {
+	if len(r.AuthMethods) == 0 {
+		return []string{"publickey"}
+	}
+	return r.AuthMethods
+}
+
+// checkRules 게임 하나의 규칙, 다른 게임과 AltName 이 겹치는지는 Config 에서 검사
+func (g *GameConfig) checkRules(errs *ruleErrors, p []any) {
+	if g.Name == "" {
+		errs.addf("", p, "game has no name")
+		return
+	}
+	owner := gameOwner(g)
+	if ReAltName.ReplaceAllString(g.Name, "") == "" {
+		errs.addf(owner, at(p, "name"), "invalid name that results in an empty AltName")
+	}
+	if g.LocalDir == "" {
+		errs.addf(owner, p, "local_dir is required")
+	}
+	// 감시할 때는 프로세스의 파일 이름이나 실행 파일의 절대 경로와 비교함
+	if strings.ContainsAny(g.ProgramName, `/\`) && !filepath.IsAbs(g.ProgramName) {
+		errs.addf(owner, at(p, "program_name"), "program_name '%s' can never match, use a file name or an absolute path", g.ProgramName)
+	}
+	for i, pattern := range g.FilePatterns {
+		if _, err := regexp.Compile(strings.ToLower(pattern)); err != nil {
+			errs.addf(owner, at(p, "file_patterns", i), "invalid regex pattern '%s': %v", pattern, err)
+		}
+	}
+	for i, glob := range g.Include {
+		if _, err := filelist.NewMatcher(nil, []string{glob}, nil); err != nil {
+			errs.addf(owner, at(p, "include", i), "%v", err)
+		}
+	}
+	for i, glob := range g.Exclude {
+		if _, err := filelist.NewMatcher(nil, nil, []string{glob}); err != nil {
+			errs.addf(owner, at(p, "exclude", i), "%v", err)
+		}
+	}
+}
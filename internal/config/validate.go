@@ -0,0 +1,433 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"scpsave/internal/filelist"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem 설정 검사에서 찾은 문제, Line 이 0 이면 위치를 모름
+type Problem struct {
+	Line    int
+	Column  int
+	Warning bool
+	Message string
+}
+
+func (p *Problem) String() string {
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", severity, p.Message)
+	}
+	if p.Column == 0 {
+		return fmt.Sprintf("%d: %s: %s", p.Line, severity, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, severity, p.Message)
+}
+
+// Validation 설정 검사 결과
+// Files 는 게임마다 동기화할 파일, 로컬 디렉터리를 읽을 수 있는 게임만 들어 있음
+type Validation struct {
+	Problems []*Problem
+	Games    []*GameConfig
+	Files    map[string][]string
+}
+
+func (v *Validation) HasErrors() bool {
+	for _, p := range v.Problems {
+		if !p.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+var reYAMLErrorLine = regexp.MustCompile(`line (\d+): (.*)`)
+
+// validator LoadConfig 와 같은 규칙으로 검사하지만 첫 문제에서 멈추지 않고 모든 문제를 YAML 노드의 위치와 함께 모음
+// 키 파일을 읽을 수 있는지, local_dir 이 있는지처럼 이 컴퓨터의 상태는 여기서만 봄
+type validator struct {
+	result *Validation
+	doc    *yaml.Node
+	failed map[string]bool // 이미 오류를 알린 위치, 같은 값을 다시 검사하지 않음
+}
+
+// Validate configPath 의 설정을 검사, 파일을 읽지 못할 때만 오류를 반환
+func Validate(configPath string) (*Validation, error) {
+	bt, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	v := &validator{result: &Validation{Files: make(map[string][]string)}, failed: make(map[string]bool)}
+	defer v.sortProblems()
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(bt, &root); err != nil {
+		v.addYAMLError(err)
+		return v.result, nil
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		v.errorf(&root, "config must be a mapping")
+		return v.result, nil
+	}
+	doc := root.Content[0]
+	v.doc = doc
+
+	v.checkKeys(doc, reflect.TypeOf(Config{}))
+
+	var config Config
+	if err := doc.Decode(&config); err != nil {
+		// 타입이 맞지 않는 값만 빼고 나머지는 읽음
+		v.addYAMLError(err)
+	}
+
+	v.checkRules(&config)
+	v.checkRemotes(&config)
+	for i, game := range config.Games {
+		if game != nil {
+			v.checkGame(at(nil, "games", i), game)
+		}
+	}
+	return v.result, nil
+}
+
+func (v *validator) errorf(node *yaml.Node, format string, args ...any) {
+	v.add(node, false, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) warnf(node *yaml.Node, format string, args ...any) {
+	v.add(node, true, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) add(node *yaml.Node, warning bool, message string) {
+	p := &Problem{Warning: warning, Message: message}
+	if node != nil {
+		p.Line, p.Column = node.Line, node.Column
+	}
+	v.result.Problems = append(v.result.Problems, p)
+}
+
+func (v *validator) addYAMLError(err error) {
+	var messages []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	} else {
+		messages = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	for _, message := range messages {
+		p := &Problem{Message: message}
+		if m := reYAMLErrorLine.FindStringSubmatch(message); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = m[2]
+		}
+		v.result.Problems = append(v.result.Problems, p)
+	}
+}
+
+func (v *validator) sortProblems() {
+	sort.SliceStable(v.result.Problems, func(i, j int) bool {
+		a, b := v.result.Problems[i], v.result.Problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// checkKeys 구조체에 없는 키를 찾음, 오타로 설정이 무시되는 경우가 많아서 경고로 알림
+func (v *validator) checkKeys(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		collectYAMLFields(t, fields)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldType, exists := fields[key.Value]
+			if !exists {
+				v.warnf(key, "unknown key '%s'", key.Value)
+				continue
+			}
+			v.checkKeys(value, fieldType)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			v.checkKeys(node.Content[i], t.Elem())
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, item := range node.Content {
+			v.checkKeys(item, t.Elem())
+		}
+	}
+}
+
+func collectYAMLFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			collectYAMLFields(field.Type, fields)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+}
+
+// nodeAt 문서에서 p 에 있는 노드, 중간에 없으면 위치를 알리기 위해 가장 가까운 상위 노드를 돌려줌
+func (v *validator) nodeAt(p []any) *yaml.Node {
+	node := v.doc
+	for _, elem := range p {
+		var next *yaml.Node
+		switch e := elem.(type) {
+		case string:
+			next = lookupNode(node, e)
+		case int:
+			if node.Kind == yaml.SequenceNode && e < len(node.Content) {
+				next = node.Content[e]
+			}
+		}
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+func lookupNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func lookupKeyNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+func (v *validator) fail(p []any, err error) {
+	v.errorf(v.nodeAt(p), "%v", err)
+	v.failed[fmt.Sprint(p)] = true
+}
+
+func (v *validator) hasFailed(p []any) bool {
+	return v.failed[fmt.Sprint(p)]
+}
+
+// checkRules 경로 설정의 변수를 바꾸고 LoadConfig 와 같은 규칙으로 검사
+// 변수를 바꾸지 못한 값은 규칙으로 다시 검사하지 않음
+func (v *validator) checkRules(config *Config) {
+	unexpanded := make(map[string]bool)
+	for _, value := range config.pathValues() {
+		if err := expandPaths(value.Value); err != nil {
+			v.fail(value.Path, &ruleError{Path: value.Path, Owner: value.Owner, Err: err})
+			unexpanded[fmt.Sprint(value.Path)] = true
+		}
+	}
+	for _, err := range config.checkRules() {
+		if !unexpanded[fmt.Sprint(err.Path)] {
+			v.fail(err.Path, err)
+		}
+	}
+}
+
+func (v *validator) checkRemotes(config *Config) {
+	used := make(map[string]bool)
+	for _, game := range config.Games {
+		if game != nil {
+			used[game.Remote] = true
+		}
+	}
+	if used[""] {
+		v.checkRemote(nil, &config.RemoteConfig)
+	}
+	remotesNode := lookupNode(v.doc, "remotes")
+	for _, name := range config.remoteNames() {
+		if name == "" || config.Remotes[name] == nil {
+			continue
+		}
+		if !used[name] {
+			v.warnf(lookupKeyNode(remotesNode, name), "remote '%s' is not used by any game", name)
+		}
+		v.checkRemote(at(nil, "remotes", name), config.Remotes[name])
+	}
+}
+
+func (v *validator) checkReadable(p []any, file, what string) {
+	if file == "" || v.hasFailed(p) {
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		v.errorf(v.nodeAt(p), "cannot read %s '%s': %v", what, file, err)
+		return
+	}
+	f.Close()
+}
+
+// checkRemote 이 컴퓨터에서 원격 설정이 가리키는 파일과 디렉터리를 확인
+func (v *validator) checkRemote(p []any, r *RemoteConfig) {
+	switch r.Backend {
+	case "", BackendSCP:
+	case BackendLocal:
+		rootPath := at(p, "remote_root")
+		if r.RemoteRoot == "" || v.hasFailed(rootPath) {
+			return
+		}
+		if info, err := os.Stat(r.RemoteRoot); err != nil || !info.IsDir() {
+			v.warnf(v.nodeAt(rootPath), "remote_root '%s' is not an existing directory", r.RemoteRoot)
+		}
+		return
+	default:
+		return
+	}
+
+	methods := r.authMethods()
+	v.checkKeyFiles(p, methods, r.PrivateKeyPath, r.CertificatePath)
+	for i, jump := range r.JumpHosts {
+		if jump == nil || (jump.PrivateKeyPath == "" && jump.CertificatePath == "") {
+			// 서버의 키를 물려받으므로 이미 확인함
+			continue
+		}
+		jumpMethods := jump.AuthMethods
+		if len(jumpMethods) == 0 {
+			jumpMethods = methods
+		}
+		v.checkKeyFiles(at(p, "jump_hosts", i), jumpMethods, jump.PrivateKeyPath, jump.CertificatePath)
+	}
+}
+
+func (v *validator) checkKeyFiles(p []any, methods []string, privateKeyPath, certificatePath string) {
+	usesKey := false
+	for _, method := range methods {
+		usesKey = usesKey || method == "publickey"
+	}
+	if usesKey {
+		v.checkReadable(at(p, "private_key_path"), privateKeyPath, "private key")
+	}
+	v.checkReadable(at(p, "certificate_path"), certificatePath, "certificate")
+}
+
+// checkGame 이 컴퓨터에서 local_dir 과 program_name 을 확인하고 동기화할 파일을 모음
+func (v *validator) checkGame(p []any, game *GameConfig) {
+	if game.Name == "" {
+		return
+	}
+	g := *game
+	g.AltName = ReAltName.ReplaceAllString(g.Name, "")
+	v.result.Games = append(v.result.Games, &g)
+
+	v.checkProgramName(p, &g)
+
+	localDirPath := at(p, "local_dir")
+	if g.LocalDir == "" || v.hasFailed(localDirPath) {
+		return
+	}
+	localDirNode := v.nodeAt(localDirPath)
+	if !filepath.IsAbs(g.LocalDir) {
+		v.warnf(localDirNode, "local_dir '%s' is relative to the current directory", g.LocalDir)
+	}
+	info, err := os.Stat(g.LocalDir)
+	if err != nil {
+		v.errorf(localDirNode, "local_dir '%s' does not exist", g.LocalDir)
+		return
+	}
+	if !info.IsDir() {
+		v.errorf(localDirNode, "local_dir '%s' is not a directory", g.LocalDir)
+		return
+	}
+
+	// 패턴의 오류는 규칙 검사에서 이미 알림
+	var fileRegExp []*regexp.Regexp
+	for _, pattern := range g.FilePatterns {
+		re, err := regexp.Compile(strings.ToLower(pattern))
+		if err != nil {
+			return
+		}
+		fileRegExp = append(fileRegExp, re)
+	}
+	matcher, err := filelist.NewMatcher(fileRegExp, g.Include, g.Exclude)
+	if err != nil {
+		return
+	}
+	files, err := filelist.ListFiles(g.LocalDir, matcher)
+	if err != nil {
+		v.errorf(localDirNode, "failed to list files: %v", err)
+		return
+	}
+	if len(files) == 0 {
+		gameNode := v.nodeAt(p)
+		patternNode := localDirNode
+		if n := lookupKeyNode(gameNode, "include"); n != nil {
+			patternNode = n
+		} else if n := lookupKeyNode(gameNode, "file_patterns"); n != nil {
+			patternNode = n
+		}
+		v.warnf(patternNode, "game '%s' has no files to sync in '%s'", g.Name, g.LocalDir)
+	}
+	v.result.Files[g.Name] = files
+}
+
+// checkProgramName 감시할 때는 프로세스의 파일 이름이나 실행 파일의 절대 경로와 비교함
+func (v *validator) checkProgramName(p []any, g *GameConfig) {
+	programPath := at(p, "program_name")
+	if g.ProgramName == "" || v.hasFailed(programPath) {
+		return
+	}
+	programNode := v.nodeAt(programPath)
+	if strings.ContainsAny(g.ProgramName, `/\`) {
+		if _, err := os.Stat(g.ProgramName); err != nil {
+			v.warnf(programNode, "program_name '%s' does not exist", g.ProgramName)
+		}
+		return
+	}
+	if runtime.GOOS == "windows" && !strings.EqualFold(filepath.Ext(g.ProgramName), ".exe") {
+		v.warnf(programNode, "program_name '%s' does not end with .exe and may never match", g.ProgramName)
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestConfig dir 의 {dir} 를 바꾼 설정 파일을 만듦
+func writeTestConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, "config.yaml")
+	content = strings.ReplaceAll(content, "{dir}", filepath.ToSlash(dir))
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// loadTestConfig p 를 LoadConfig 로 읽음, 전역 설정은 테스트가 끝나면 되돌림
+func loadTestConfig(t *testing.T, p string) error {
+	t.Helper()
+	configPath, value := ConfigPath, Value
+	t.Cleanup(func() {
+		ConfigPath, Value = configPath, value
+	})
+	ConfigPath = p
+	return LoadConfig()
+}
+
+func TestProblemString(t *testing.T) {
+	tests := []struct {
+		problem Problem
+		want    string
+	}{
+		{Problem{Line: 3, Column: 5, Message: "bad"}, "3:5: error: bad"},
+		{Problem{Line: 3, Warning: true, Message: "odd"}, "3: warning: odd"},
+		{Problem{Message: "bad"}, "error: bad"},
+	}
+	for _, tt := range tests {
+		if got := tt.problem.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestConfig(t, dir, `remote_root: saves
+auth_methods: [agent]
+remotes:
+  nas:
+    backend: local
+    remote_root: {dir}
+games:
+  - name: Game1
+    local_dir: {dir}/missing
+    file_patterns: ['(']
+  - name: Game-1
+    local_dir: {dir}
+    remote: nas
+    unknown_key: 1
+`)
+
+	result, err := Validate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"1:1: error: server_address is required",
+		"1:1: error: username is required",
+		"1:14: error: remote_root 'saves' must be an absolute path on the server",
+		"9:16: error: local_dir '" + filepath.Join(dir, "missing") + "' does not exist",
+		"10:21: error: game 'Game1': invalid regex pattern '(': error parsing regexp: missing closing ): `(`",
+		"11:11: error: game 'Game-1': same AltName 'Game1' as game 'Game1'",
+		"14:5: warning: unknown key 'unknown_key'",
+	}
+	var got []string
+	for _, problem := range result.Problems {
+		got = append(got, problem.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !result.HasErrors() {
+		t.Errorf("HasErrors() = false")
+	}
+}
+
+func TestValidateListsFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.sav", "b.tmp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := writeTestConfig(t, dir, `backend: local
+remote_root: {dir}
+games:
+  - name: Game1
+    local_dir: {dir}
+    exclude: ['*.tmp', config.yaml]
+`)
+
+	result, err := Validate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Problems) != 0 {
+		t.Errorf("problems = %v", result.Problems)
+	}
+	if files := result.Files["Game1"]; len(files) != 1 || files[0] != "a.sav" {
+		t.Errorf("files = %v, want [a.sav]", files)
+	}
+}
+
+// LoadConfig 와 Validate 는 같은 규칙을 씀
+func TestLoadConfigAgreesWithValidate(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"scp", "server_address: host:22\nusername: user\nprivate_key_path: {dir}/id_ed25519\nremote_root: /saves\n", true},
+		{"agent", "server_address: host:22\nusername: user\nauth_methods: [agent]\nremote_root: /saves\n", true},
+		{"local", "backend: local\nremote_root: {dir}\n", true},
+		{"no server_address", "username: user\nauth_methods: [agent]\nremote_root: /saves\n", false},
+		{"no username", "server_address: host:22\nauth_methods: [agent]\nremote_root: /saves\n", false},
+		{"relative remote_root", "server_address: host:22\nusername: user\nauth_methods: [agent]\nremote_root: saves\n", false},
+		{"no port", "server_address: host\nusername: user\nauth_methods: [agent]\nremote_root: /saves\n", false},
+		{"no private key", "server_address: host:22\nusername: user\nremote_root: /saves\n", false},
+		{"relative local remote_root", "backend: local\nremote_root: saves\n", false},
+		{"unknown backend", "backend: ftp\nremote_root: /saves\n", false},
+		{"unknown variable", "backend: local\nremote_root: '{unknown}/saves'\n", false},
+		{"jump host without address", "server_address: host:22\nusername: user\nauth_methods: [agent]\nremote_root: /saves\njump_hosts:\n  - username: jump\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := writeTestConfig(t, dir, tt.config+"games:\n  - name: Game1\n    local_dir: {dir}\n")
+			result, err := Validate(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loadErr := loadTestConfig(t, p)
+			if valid := !result.HasErrors(); valid != tt.valid {
+				t.Errorf("Validate valid = %v, want %v: %v", valid, tt.valid, result.Problems)
+			}
+			if valid := loadErr == nil; valid != tt.valid {
+				t.Errorf("LoadConfig valid = %v, want %v: %v", valid, tt.valid, loadErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSkipsUnusedDefaultRemote(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestConfig(t, dir, `remotes:
+  nas:
+    backend: local
+    remote_root: {dir}
+games:
+  - name: Game1
+    local_dir: {dir}
+    remote: nas
+`)
+	if err := loadTestConfig(t, p); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if game := Value.Games[0]; game.RemoteRoot != filepath.Clean(dir) || game.AltName != "Game1" {
+		t.Errorf("game = %+v", game)
+	}
+	result, err := Validate(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.HasErrors() {
+		t.Errorf("problems = %v", result.Problems)
+	}
+}
+
+func TestLoadConfigLocalBackupCount(t *testing.T) {
+	dir := t.TempDir()
+	for config, want := range map[string]int{"": DefaultLocalBackupCount, "local_backup_count: 0\n": 0, "local_backup_count: 3\n": 3} {
+		p := writeTestConfig(t, dir, config+"backend: local\nremote_root: {dir}\ngames:\n  - name: Game1\n    local_dir: {dir}\n")
+		if err := loadTestConfig(t, p); err != nil {
+			t.Fatal(err)
+		}
+		if got := Value.LocalBackups(); got != want {
+			t.Errorf("%q: LocalBackups() = %d, want %d", config, got, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package conio
+
+import "fmt"
+
+func ConfirmHostKey(host, keyType, fingerprint string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fmt.Printf("The authenticity of host '%s' can't be established.\n", host)
+	fmt.Printf("%s key fingerprint is %s.\n", keyType, fingerprint)
+
+	for range 3 {
+		fmt.Print("Are you sure you want to trust this host? [Y or N]: ")
+
+		var choice string
+		fmt.Scanln(&choice)
+		fmt.Println()
+
+		switch choice {
+		case "y", "Y":
+			return true
+		case "n", "N":
+			return false
+		default:
+			fmt.Println("Invalid choice. Please try again.")
+		}
+	}
+
+	fmt.Println("Host key confirmation failed after 3 attempts.")
+	return false
+}
@@ -0,0 +1,30 @@
+package conio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+func ReadPassphrase(keyPath string) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	fmt.Printf("Enter passphrase for key '%s': ", keyPath)
+	defer fmt.Println()
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		return term.ReadPassword(fd)
+	}
+
+	// 터미널이 아니면 입력을 숨길 수 없으므로 한 줄을 그대로 읽음
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
+}
@@ -3,6 +3,8 @@ package conio
 import (
 	"fmt"
 	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"time"
 )
 
 type ResolveMethod int
@@ -13,12 +15,16 @@ const (
 	Abort                              // 중단
 )
 
-func ResolveConflict(game *config.GameConfig) ResolveMethod {
+// ResolveConflict 로컬과 원격에서 모두 바뀐 파일 하나를 어느 쪽으로 맞출지 물어봄
+// local 이나 remote 가 nil 이면 그쪽에서 지워진 파일
+func ResolveConflict(game *config.GameConfig, relPath string, local, remote *filelist.FileMetadata) ResolveMethod {
 	mu.Lock()
 	defer mu.Unlock()
 
 	for range 3 {
-		fmt.Printf("[%s] Save files conflict has occurred.\n", game.Name)
+		fmt.Printf("[%s] Save file conflict has occurred: %s\n", game.Name, relPath)
+		fmt.Printf("  Local : %s\n", describeFile(local))
+		fmt.Printf("  Remote: %s\n", describeFile(remote))
 		fmt.Println("(L) Load 'Local' file")
 		fmt.Println("(R) Load 'Remote' file")
 		fmt.Println("(A) Abort")
@@ -44,3 +50,11 @@ func ResolveConflict(game *config.GameConfig) ResolveMethod {
 	fmt.Println("Aborting resolve process...")
 	return Abort
 }
+
+func describeFile(metadata *filelist.FileMetadata) string {
+	if metadata == nil {
+		return "deleted"
+	}
+	modifiedTime := time.Unix(0, metadata.ModifiedTime).Format("2006-01-02 15:04:05")
+	return fmt.Sprintf("%d bytes, modified %s", metadata.Size, modifiedTime)
+}
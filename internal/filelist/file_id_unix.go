@@ -0,0 +1,15 @@
+//go:build !windows
+
+package filelist
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileID(_ string, info os.FileInfo) (uint64, error) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino), nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package filelist
+
+import (
+	"os"
+	"syscall"
+)
+
+func fileID(p string, _ os.FileInfo) (uint64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var d syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &d); err != nil {
+		return 0, err
+	}
+	return uint64(d.FileIndexHigh)<<32 | uint64(d.FileIndexLow), nil
+}
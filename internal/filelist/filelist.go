@@ -7,8 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strings"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +18,14 @@ type FileMetadata struct {
 	Hash         string // SHA-512 hash of the file content
 }
 
+// SameContent 수정 시간은 빼고 내용만 비교
+func (m *FileMetadata) SameContent(other *FileMetadata) bool {
+	if m == nil || other == nil {
+		return m == other
+	}
+	return m.Hash == other.Hash && m.Size == other.Size
+}
+
 type FileList map[string]*FileMetadata
 
 func LoadFileList(filelistPath string) (FileList, error) {
@@ -38,25 +45,60 @@ func LoadFileList(filelistPath string) (FileList, error) {
 	return fileList, nil
 }
 
+// Validate 다른 컴퓨터가 올린 목록을 쓰기 전에 검사
+// 경로가 로컬 디렉터리 밖을 가리키거나(절대 경로, "..") 해시가 SHA-512 가 아니면 거부
+func (fl FileList) Validate() error {
+	for relPath, meta := range fl {
+		if !filepath.IsLocal(relPath) {
+			return fmt.Errorf("unsafe path '%s' in file list", relPath)
+		}
+		if meta == nil || !IsHash(meta.Hash) {
+			return fmt.Errorf("invalid hash for '%s' in file list", relPath)
+		}
+	}
+	return nil
+}
+
+// IsHash 소문자 16진수로 쓴 SHA-512 인지
+func IsHash(s string) bool {
+	if len(s) != sha512.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Save 임시 파일에 쓴 뒤 이름을 바꿔서 중간에 끊겨도 이전 내용이 남게 함
 func (fl FileList) Save(filelistPath string) error {
 	bt, err := yaml.Marshal(fl)
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(filepath.Clean(filelistPath), bt, 0644); err != nil {
+	filelistPath = filepath.Clean(filelistPath)
+	tempPath := filelistPath + ".temp"
+	if err := os.WriteFile(tempPath, bt, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, filelistPath); err != nil {
+		_ = os.Remove(tempPath)
 		return err
 	}
 
 	return nil
 }
 
+// Diff 내용이 다르거나 base 에 없는 파일과 base 에만 있는 파일
 func (fl FileList) Diff(base FileList) (updated, removed FileList) {
 	updated = make(FileList)
 	removed = make(FileList)
 
 	for relPath, meta := range fl {
-		if otherMeta, exists := base[relPath]; !exists || *meta != *otherMeta {
+		if otherMeta, exists := base[relPath]; !exists || !meta.SameContent(otherMeta) {
 			updated[relPath] = meta
 		}
 	}
@@ -70,13 +112,14 @@ func (fl FileList) Diff(base FileList) (updated, removed FileList) {
 	return updated, removed
 }
 
+// Equal 같은 파일들이 같은 내용을 가지는지 비교, 수정 시간만 다른 것은 같은 것으로 봄
 func (fl FileList) Equal(other FileList) bool {
 	if len(fl) != len(other) {
 		return false
 	}
 
 	for path, meta := range fl {
-		if otherMeta, exists := other[path]; !exists || *meta != *otherMeta {
+		if otherMeta, exists := other[path]; !exists || !meta.SameContent(otherMeta) {
 			return false
 		}
 	}
@@ -84,53 +127,111 @@ func (fl FileList) Equal(other FileList) bool {
 	return true
 }
 
-func MakeFileList(localDir string, filePatterns []*regexp.Regexp) (FileList, error) {
-	localDir, err := filepath.Abs(localDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path for local directory '%s': %w", localDir, err)
+// Identical 수정 시간까지 모두 같은지 비교
+func (fl FileList) Identical(other FileList) bool {
+	if len(fl) != len(other) {
+		return false
 	}
 
+	for path, meta := range fl {
+		if otherMeta, exists := other[path]; !exists || *meta != *otherMeta {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MakeFileList matcher 에 맞는 파일의 목록을 만듦
+// cache 가 nil 이 아니면 바뀌지 않은 파일은 캐시된 해시를 사용
+func MakeFileList(localDir string, matcher *Matcher, cache *HashCache) (FileList, error) {
 	fileList := make(FileList)
 
-	err = filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+	err := walkFiles(localDir, matcher, func(p, relpath string, info os.FileInfo) error {
+		h, err := cachedFileHash(cache, p, relpath, info)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to calculate hash for file '%s': %w", p, err)
 		}
 
-		if info.IsDir() {
-			return nil
+		fileList[relpath] = &FileMetadata{
+			ModifiedTime: info.ModTime().UnixNano(),
+			Size:         info.Size(),
+			Hash:         h,
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		for _, pattern := range filePatterns {
-			relpath, err := filepath.Rel(localDir, p)
-			if err != nil {
-				return fmt.Errorf("failed to get relative path for '%s': %w", p, err)
-			}
+	return fileList, nil
+}
 
-			if pattern.MatchString(strings.ToLower(relpath)) {
-				h, err := calculateFileHash(p)
-				if err != nil {
-					return fmt.Errorf("failed to calculate hash for file '%s': %w", p, err)
-				}
+// ListFiles 해시를 계산하지 않고 matcher 에 맞는 파일의 상대 경로만 모음
+func ListFiles(localDir string, matcher *Matcher) ([]string, error) {
+	var relPaths []string
+	err := walkFiles(localDir, matcher, func(_, relpath string, _ os.FileInfo) error {
+		relPaths = append(relPaths, relpath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(relPaths)
+	return relPaths, nil
+}
 
-				fileList[relpath] = &FileMetadata{
-					ModifiedTime: info.ModTime().UnixNano(),
-					Size:         info.Size(),
-					Hash:         h,
-				}
+func walkFiles(localDir string, matcher *Matcher, fn func(p, relpath string, info os.FileInfo) error) error {
+	localDir, err := filepath.Abs(localDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for local directory '%s': %w", localDir, err)
+	}
 
-				return nil // Stop checking other patterns for this file
+	err = filepath.Walk(localDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relpath, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path for '%s': %w", p, err)
+		}
+
+		if info.IsDir() {
+			if relpath != "." && matcher.SkipDir(relpath) {
+				return filepath.SkipDir
 			}
+			return nil
 		}
 
-		return nil
+		if !matcher.Match(relpath) {
+			return nil
+		}
+		return fn(p, relpath, info)
 	})
-
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk through directory '%s': %w", localDir, err)
+		return fmt.Errorf("failed to walk through directory '%s': %w", localDir, err)
 	}
+	return nil
+}
 
-	return fileList, nil
+func cachedFileHash(cache *HashCache, p, relPath string, info os.FileInfo) (string, error) {
+	if cache == nil {
+		return calculateFileHash(p)
+	}
+	id, err := fileID(p, info)
+	if err != nil {
+		return "", err
+	}
+	if h, ok := cache.lookup(relPath, info, id); ok {
+		return h, nil
+	}
+	h, err := calculateFileHash(p)
+	if err != nil {
+		return "", err
+	}
+	cache.store(relPath, info, id, h)
+	return h, nil
 }
 
 func calculateFileHash(filePath string) (string, error) {
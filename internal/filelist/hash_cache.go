@@ -0,0 +1,100 @@
+package filelist
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// racyDuration 이보다 최근에 수정된 파일은 캐시하지 않음
+const racyDuration = 2 * time.Second
+
+type hashCacheEntry struct {
+	Size         int64  `yaml:"size"`
+	ModifiedTime int64  `yaml:"modified_time"`
+	FileID       uint64 `yaml:"file_id"` // inode, Windows 에서는 file index
+	Hash         string `yaml:"hash"`
+}
+
+// HashCache 크기, 수정 시간, inode 가 그대로인 파일의 해시를 다시 계산하지 않도록 기억
+type HashCache struct {
+	path    string
+	entries map[string]*hashCacheEntry
+	seen    map[string]struct{}
+	dirty   bool
+}
+
+// LoadHashCache 캐시 파일이 없거나 읽을 수 없으면 빈 캐시로 시작
+func LoadHashCache(cachePath string) *HashCache {
+	c := &HashCache{
+		path:    filepath.Clean(cachePath),
+		entries: make(map[string]*hashCacheEntry),
+		seen:    make(map[string]struct{}),
+	}
+	if bt, err := os.ReadFile(c.path); err == nil {
+		if err := yaml.Unmarshal(bt, &c.entries); err != nil || c.entries == nil {
+			c.entries = make(map[string]*hashCacheEntry)
+		}
+	}
+	return c
+}
+
+// Reset 모든 해시를 다시 계산하게 함
+func (c *HashCache) Reset() {
+	c.entries = make(map[string]*hashCacheEntry)
+	c.dirty = true
+}
+
+func (c *HashCache) lookup(relPath string, info os.FileInfo, fileID uint64) (string, bool) {
+	c.seen[relPath] = struct{}{}
+	entry, exists := c.entries[relPath]
+	if !exists || entry.Size != info.Size() || entry.ModifiedTime != info.ModTime().UnixNano() || entry.FileID != fileID {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+func (c *HashCache) store(relPath string, info os.FileInfo, fileID uint64, hash string) {
+	c.seen[relPath] = struct{}{}
+	if time.Since(info.ModTime()) < racyDuration {
+		// 같은 수정 시간 안에 다시 쓰일 수 있으므로 기억하지 않음
+		delete(c.entries, relPath)
+		return
+	}
+	c.entries[relPath] = &hashCacheEntry{
+		Size:         info.Size(),
+		ModifiedTime: info.ModTime().UnixNano(),
+		FileID:       fileID,
+		Hash:         hash,
+	}
+	c.dirty = true
+}
+
+// Save 이번에 보지 못한 파일은 빼고 저장
+func (c *HashCache) Save() error {
+	for relPath := range c.entries {
+		if _, exists := c.seen[relPath]; !exists {
+			delete(c.entries, relPath)
+			c.dirty = true
+		}
+	}
+	c.seen = make(map[string]struct{})
+	if !c.dirty {
+		return nil
+	}
+
+	bt, err := yaml.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, bt, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,172 @@
+package filelist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupHashCache 수정 시간이 오래된 a.sav 와 그 해시를 기억한 캐시 파일을 만듦
+func setupHashCache(t *testing.T) (dir, cachePath string, matcher *Matcher) {
+	t.Helper()
+	dir = t.TempDir()
+	cachePath = filepath.Join(t.TempDir(), "hashcache.yaml")
+	writeOldFile(t, filepath.Join(dir, "a.sav"), "first")
+
+	matcher, err := NewMatcher(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := LoadHashCache(cachePath)
+	if _, err := MakeFileList(dir, matcher, cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	return dir, cachePath, matcher
+}
+
+// writeOldFile 바로 수정된 파일은 캐시하지 않으므로 수정 시간을 한 시간 전으로 돌림
+func writeOldFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setOldTime(t, p, time.Hour)
+}
+
+func setOldTime(t *testing.T, p string, ago time.Duration) {
+	t.Helper()
+	old := time.Now().Add(-ago)
+	if err := os.Chtimes(p, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// poisonHashCache 캐시의 해시를 바꿔서 캐시를 썼는지 알 수 있게 함
+func poisonHashCache(t *testing.T, cachePath string) {
+	t.Helper()
+	cache := LoadHashCache(cachePath)
+	for _, entry := range cache.entries {
+		entry.Hash = "cached"
+	}
+	cache.dirty = true
+	for relPath := range cache.entries {
+		cache.seen[relPath] = struct{}{}
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hashOf(t *testing.T, dir string, matcher *Matcher, cache *HashCache) string {
+	t.Helper()
+	files, err := MakeFileList(dir, matcher, cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files["a.sav"].Hash
+}
+
+func TestHashCacheSkipsUnchangedFile(t *testing.T) {
+	dir, cachePath, matcher := setupHashCache(t)
+	poisonHashCache(t, cachePath)
+
+	if got := hashOf(t, dir, matcher, LoadHashCache(cachePath)); got != "cached" {
+		t.Errorf("unchanged file was hashed again")
+	}
+}
+
+func TestHashCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, p string)
+	}{
+		{"size", func(t *testing.T, p string) {
+			info, _ := os.Stat(p)
+			writeOldFile(t, p, "second, longer")
+			if err := os.Chtimes(p, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"mtime", func(t *testing.T, p string) {
+			if err := os.WriteFile(p, []byte("other"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			setOldTime(t, p, 30*time.Minute)
+		}},
+		{"inode", func(t *testing.T, p string) {
+			// 같은 크기와 수정 시간으로 파일을 바꿔치기
+			info, _ := os.Stat(p)
+			replacement := p + ".new"
+			if err := os.WriteFile(replacement, []byte("other"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(replacement, info.ModTime(), info.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(replacement, p); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cachePath, matcher := setupHashCache(t)
+			poisonHashCache(t, cachePath)
+			tt.change(t, filepath.Join(dir, "a.sav"))
+
+			if got := hashOf(t, dir, matcher, LoadHashCache(cachePath)); got == "cached" {
+				t.Errorf("changed file used the cached hash")
+			}
+		})
+	}
+}
+
+func TestHashCacheReset(t *testing.T) {
+	dir, cachePath, matcher := setupHashCache(t)
+	poisonHashCache(t, cachePath)
+
+	cache := LoadHashCache(cachePath)
+	cache.Reset()
+	if got := hashOf(t, dir, matcher, cache); got == "cached" {
+		t.Errorf("hash was not recomputed after Reset")
+	}
+}
+
+func TestHashCacheSkipsRecentFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.sav"), []byte("now"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := NewMatcher(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := LoadHashCache(filepath.Join(t.TempDir(), "hashcache.yaml"))
+	if _, err := MakeFileList(dir, matcher, cache); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := cache.entries["a.sav"]; exists {
+		t.Errorf("file modified just now was cached")
+	}
+}
+
+func TestHashCacheSaveDropsMissingFiles(t *testing.T) {
+	dir, cachePath, matcher := setupHashCache(t)
+	if err := os.Remove(filepath.Join(dir, "a.sav")); err != nil {
+		t.Fatal(err)
+	}
+	cache := LoadHashCache(cachePath)
+	if _, err := MakeFileList(dir, matcher, cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if entries := LoadHashCache(cachePath).entries; len(entries) != 0 {
+		t.Errorf("cache still has %v", entries)
+	}
+}
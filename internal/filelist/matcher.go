@@ -0,0 +1,168 @@
+package filelist
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Matcher 동기화할 파일을 고르는 규칙
+//   - regexps: 소문자로 바꾼 상대 경로에 대한 정규식, 하나라도 맞으면 포함
+//   - include: gitignore 형식의 glob, 뒤에 있는 규칙이 우선이며 '!' 로 시작하면 제외
+//   - exclude: gitignore 형식의 glob, 맞으면 항상 제외
+//
+// regexps 와 포함 규칙이 하나도 없으면 디렉터리 전체를 동기화함
+type Matcher struct {
+	regexps []*regexp.Regexp
+	include []*globRule
+	exclude []*globRule
+	all     bool
+}
+
+// globRule glob 하나를 컴파일한 것
+// '/' 가 앞이나 중간에 없으면 어느 깊이에서나 이름으로 맞추고, '/' 로 끝나면 디렉터리에만 맞춤
+// 디렉터리에 맞으면 그 아래의 파일 모두에 맞음
+type globRule struct {
+	pattern string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+func NewMatcher(regexps []*regexp.Regexp, include, exclude []string) (*Matcher, error) {
+	m := &Matcher{regexps: regexps, all: len(regexps) == 0}
+	for _, pattern := range include {
+		rule, err := compileGlob(pattern, true)
+		if err != nil {
+			return nil, err
+		}
+		if !rule.negate {
+			m.all = false
+		}
+		m.include = append(m.include, rule)
+	}
+	for _, pattern := range exclude {
+		rule, err := compileGlob(pattern, false)
+		if err != nil {
+			return nil, err
+		}
+		m.exclude = append(m.exclude, rule)
+	}
+	return m, nil
+}
+
+// Match relPath 는 로컬 디렉터리 기준의 상대 경로
+func (m *Matcher) Match(relPath string) bool {
+	lower := strings.ToLower(relPath)
+	slashed := filepath.ToSlash(lower)
+
+	matched := m.all
+	if !matched {
+		for _, re := range m.regexps {
+			if re.MatchString(lower) {
+				matched = true
+				break
+			}
+		}
+	}
+	for _, rule := range m.include {
+		if rule.match(slashed) {
+			matched = !rule.negate
+		}
+	}
+	if !matched {
+		return false
+	}
+	for _, rule := range m.exclude {
+		if rule.match(slashed) {
+			return false
+		}
+	}
+	return true
+}
+
+// SkipDir 디렉터리 전체가 제외되어 들어갈 필요가 없는지
+func (m *Matcher) SkipDir(relPath string) bool {
+	slashed := filepath.ToSlash(strings.ToLower(relPath))
+	for _, rule := range m.exclude {
+		if rule.re.MatchString(slashed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *globRule) match(relPath string) bool {
+	if !r.dirOnly && r.re.MatchString(relPath) {
+		return true
+	}
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		if r.re.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob '\' 는 Windows 에서 쓰기 쉽도록 '/' 와 같게 봄
+func compileGlob(pattern string, allowNegate bool) (*globRule, error) {
+	rule := &globRule{pattern: pattern}
+	p := strings.ToLower(strings.ReplaceAll(pattern, `\`, "/"))
+	if allowNegate && strings.HasPrefix(p, "!") {
+		rule.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		rule.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	anchored := strings.Contains(p, "/")
+	p = strings.TrimLeft(p, "/")
+	if p == "" {
+		return nil, fmt.Errorf("empty glob pattern '%s'", pattern)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	if !anchored {
+		sb.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class in glob pattern '%s'", pattern)
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+	}
+	rule.re = re
+	return rule, nil
+}
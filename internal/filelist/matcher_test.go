@@ -0,0 +1,169 @@
+package filelist
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestMatcherMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		regexps []string
+		include []string
+		exclude []string
+		paths   map[string]bool
+	}{
+		{
+			name:  "everything by default",
+			paths: map[string]bool{"a.sav": true, "d/e/f.dat": true},
+		},
+		{
+			name:    "regexps only",
+			regexps: []string{`.+\.sav`},
+			paths:   map[string]bool{"a.sav": true, "d/B.SAV": true, "a.dat": false},
+		},
+		{
+			name:    "name pattern matches at any depth",
+			include: []string{"*.sav"},
+			paths:   map[string]bool{"a.sav": true, "d/e/b.sav": true, "C.SAV": true, "a.sav.bak": false},
+		},
+		{
+			name:    "leading slash anchors to the root",
+			include: []string{"/top.sav"},
+			paths:   map[string]bool{"top.sav": true, "d/top.sav": false},
+		},
+		{
+			name:    "slash in the middle anchors to the root",
+			include: []string{"saves/*.sav"},
+			paths:   map[string]bool{"saves/a.sav": true, "x/saves/a.sav": false, "saves/sub/a.sav": false},
+		},
+		{
+			name:    "leading double star",
+			include: []string{"**/saves/*.sav"},
+			paths:   map[string]bool{"saves/a.sav": true, "x/y/saves/a.sav": true, "saves/sub/a.sav": false},
+		},
+		{
+			name:    "double star in the middle",
+			include: []string{"a/**/b.sav"},
+			paths:   map[string]bool{"a/b.sav": true, "a/x/y/b.sav": true, "c/a/b.sav": false},
+		},
+		{
+			name:    "trailing double star",
+			include: []string{"saves/**"},
+			paths:   map[string]bool{"saves/a": true, "saves/a/b.sav": true, "saves": false, "other/a": false},
+		},
+		{
+			name:    "trailing slash matches directories only",
+			include: []string{"saves/"},
+			paths:   map[string]bool{"saves/a/b.sav": true, "x/saves/a.sav": true, "saves": false, "x/saves": false},
+		},
+		{
+			name:    "later negation wins",
+			include: []string{"*.sav", "!old/"},
+			paths:   map[string]bool{"a.sav": true, "old/a.sav": false, "x/old/a.sav": false},
+		},
+		{
+			name:    "later inclusion wins",
+			include: []string{"!old/", "*.sav"},
+			paths:   map[string]bool{"old/a.sav": true},
+		},
+		{
+			name:    "re-include inside excluded directory",
+			include: []string{"**/*.sav", "!backup/**", "backup/keep.sav"},
+			paths:   map[string]bool{"a.sav": true, "backup/x.sav": false, "backup/keep.sav": true},
+		},
+		{
+			name:    "negation alone keeps everything else",
+			include: []string{"!*.log"},
+			paths:   map[string]bool{"a.sav": true, "d/a.log": false},
+		},
+		{
+			name:    "negation after regexps",
+			regexps: []string{`.+\.dat`},
+			include: []string{"!old/"},
+			paths:   map[string]bool{"h.tmp.dat": true, "old/g.dat": false, "a.sav": false},
+		},
+		{
+			name:    "exclude always wins",
+			include: []string{"**/*.sav", "tmp/keep.sav"},
+			exclude: []string{"tmp/", "*.bak.sav"},
+			paths:   map[string]bool{"a.sav": true, "tmp/keep.sav": false, "a.bak.sav": false},
+		},
+		{
+			name:    "exclude does not negate",
+			exclude: []string{"!important.sav"},
+			paths:   map[string]bool{"!important.sav": false, "important.sav": true},
+		},
+		{
+			name:    "wildcards and character classes",
+			include: []string{"s?.[!x]at", "slot[0-9].sav"},
+			paths:   map[string]bool{"s1.dat": true, "s1.xat": false, "s12.dat": false, "s/.dat": false, "slot3.sav": true, "slota.sav": false},
+		},
+		{
+			name:    "single star does not cross directories",
+			include: []string{"/s*.sav"},
+			paths:   map[string]bool{"slot.sav": true, "s/slot.sav": false},
+		},
+		{
+			name:    "backslash is a separator",
+			include: []string{`saves\*.sav`},
+			paths:   map[string]bool{"saves/a.sav": true, "x/saves/a.sav": false},
+		},
+		{
+			name:    "special characters are literal",
+			include: []string{"save (1).sav", "a+b.sav"},
+			paths:   map[string]bool{"save (1).sav": true, "save 1.sav": false, "a+b.sav": true, "aab.sav": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var regexps []*regexp.Regexp
+			for _, expr := range tt.regexps {
+				regexps = append(regexps, regexp.MustCompile(expr))
+			}
+			m, err := NewMatcher(regexps, tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("NewMatcher failed: %v", err)
+			}
+			for relPath, want := range tt.paths {
+				if got := m.Match(filepath.FromSlash(relPath)); got != want {
+					t.Errorf("Match(%q) = %v, want %v", relPath, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatcherSkipDir(t *testing.T) {
+	m, err := NewMatcher(nil, []string{"*.sav", "!old/"}, []string{"cache/", "/tmp", "logs/**"})
+	if err != nil {
+		t.Fatalf("NewMatcher failed: %v", err)
+	}
+
+	tests := map[string]bool{
+		"cache":     true,
+		"a/cache":   true,
+		"cached":    false,
+		"tmp":       true,
+		"a/tmp":     false,
+		"logs":      false,
+		"logs/old":  true,
+		"old":       false, // 뒤의 규칙이 다시 포함할 수 있으므로 들어가 봐야 함
+		"saves/sub": false,
+	}
+	for relPath, want := range tests {
+		if got := m.SkipDir(filepath.FromSlash(relPath)); got != want {
+			t.Errorf("SkipDir(%q) = %v, want %v", relPath, got, want)
+		}
+	}
+}
+
+func TestCompileGlobErrors(t *testing.T) {
+	for _, pattern := range []string{"", "/", "!", "!/", "[abc", "saves/[a-"} {
+		if _, err := NewMatcher(nil, []string{pattern}, nil); err == nil {
+			t.Errorf("NewMatcher accepted include pattern %q", pattern)
+		}
+	}
+}
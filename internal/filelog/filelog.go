@@ -7,8 +7,8 @@ import (
 	"os"
 )
 
-func SetFileLog() (func(), error) {
-	f, err := os.Create("./scpsave.log")
+func SetFileLog(logPath string) (func(), error) {
+	f, err := os.Create(logPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create log file: %w", err)
 	}
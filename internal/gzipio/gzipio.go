@@ -1,52 +1,171 @@
 package gzipio
 
 import (
-	"bytes"
 	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
 	"io"
 	"os"
 )
 
-func NewCompressReader(infile string) (io.Reader, error) {
-	f, err := os.Open(infile)
+// CompressReader 압축한 내용을 임시 파일에 두고 읽음
+// 전송 전에 압축된 크기를 알 수 있고 메모리 사용량이 파일 크기와 무관함
+// 같은 입력은 항상 같은 압축 결과가 되므로 중단된 전송을 이어서 할 수 있음
+type CompressReader struct {
+	f              *os.File
+	size           int64
+	hash           string
+	compressedHash string
+}
+
+func NewCompressReader(infile string) (*CompressReader, error) {
+	in, err := os.Open(infile)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	defer in.Close()
 
-	buf := &bytes.Buffer{}
-	w := gzip.NewWriter(buf)
-	defer w.Close()
+	tmp, err := os.CreateTemp("", "scpsave-*.gz")
+	if err != nil {
+		return nil, err
+	}
+	r := &CompressReader{f: tmp}
+
+	h := sha512.New()
+	ch := sha512.New()
+	w := gzip.NewWriter(io.MultiWriter(tmp, ch))
+	if _, err := io.Copy(io.MultiWriter(w, h), in); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		r.Close()
+		return nil, err
+	}
+	r.hash = hex.EncodeToString(h.Sum(nil))
+	r.compressedHash = hex.EncodeToString(ch.Sum(nil))
 
-	if _, err := io.Copy(w, f); err != nil {
+	r.size, err = tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		r.Close()
 		return nil, err
 	}
-	w.Close()
-	return bytes.NewBuffer(buf.Bytes()), nil
+	return r, nil
+}
+
+func (r *CompressReader) Read(p []byte) (int, error) {
+	return r.f.Read(p)
+}
+
+func (r *CompressReader) Seek(offset int64, whence int) (int64, error) {
+	return r.f.Seek(offset, whence)
+}
+
+// Size 압축된 크기
+func (r *CompressReader) Size() int64 {
+	return r.size
+}
+
+// Hash 압축 전 내용의 SHA-512
+func (r *CompressReader) Hash() string {
+	return r.hash
+}
+
+// CompressedHash 압축된 내용의 SHA-512
+func (r *CompressReader) CompressedHash() string {
+	return r.compressedHash
+}
+
+func (r *CompressReader) Close() error {
+	err := r.f.Close()
+	if rerr := os.Remove(r.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// DecompressWriter 쓰는 즉시 압축을 풀어서 outfile 에 기록하고 풀린 내용의 SHA-512 를 계산
+type DecompressWriter struct {
+	pw       *io.PipeWriter
+	done     chan error
+	hash     string
+	writeErr error
+	closed   bool
+	err      error
+}
+
+func NewDecompressWriter(outfile string) *DecompressWriter {
+	pr, pw := io.Pipe()
+	w := &DecompressWriter{pw: pw, done: make(chan error, 1)}
+	go func() {
+		hash, err := decompressTo(outfile, pr)
+		pr.CloseWithError(err)
+		w.hash = hash
+		w.done <- err
+	}()
+	return w
+}
+
+func (w *DecompressWriter) Write(p []byte) (int, error) {
+	n, err := w.pw.Write(p)
+	if err != nil && w.writeErr == nil {
+		w.writeErr = err
+	}
+	return n, err
+}
+
+// Failed 쓰는 도중에 압축 해제가 실패했는지, 그렇다면 쓴 내용이 깨진 것
+func (w *DecompressWriter) Failed() bool {
+	return w.writeErr != nil
 }
 
-func NewDecompressWriter(outfile string) io.WriteCloser {
-	return &decompressWriter{outfile: outfile}
+// Close 남은 내용을 모두 기록하고 압축 해제 결과를 반환
+func (w *DecompressWriter) Close() error {
+	return w.CloseWithError(nil)
 }
 
-type decompressWriter struct {
-	outfile string
-	buf     bytes.Buffer
+// CloseWithError 전송이 실패한 경우 압축 해제를 중단, 여러 번 불러도 처음 결과를 돌려줌
+func (w *DecompressWriter) CloseWithError(err error) error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+	w.pw.CloseWithError(err)
+	w.err = <-w.done
+	if err != nil {
+		w.err = err
+	}
+	return w.err
 }
 
-func (w *decompressWriter) Write(p []byte) (n int, err error) {
-	return w.buf.Write(p)
+// Hash 풀린 내용의 SHA-512, Close 가 성공한 뒤에만 유효함
+func (w *DecompressWriter) Hash() string {
+	return w.hash
 }
 
-func (w *decompressWriter) Close() error {
-	r, err := gzip.NewReader(bytes.NewReader(w.buf.Bytes()))
+func decompressTo(outfile string, r io.Reader) (string, error) {
+	zr, err := gzip.NewReader(r)
 	if err != nil {
-		return err
+		return "", err
 	}
-	defer r.Close()
-	decompressed, err := io.ReadAll(r)
+	defer zr.Close()
+
+	f, err := os.Create(outfile)
 	if err != nil {
-		return err
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), zr); err != nil {
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
 	}
-	return os.WriteFile(w.outfile, decompressed, 0644)
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
@@ -0,0 +1,63 @@
+package gzipio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompressAndDecompress(t *testing.T) {
+	dir := t.TempDir()
+	infile := filepath.Join(dir, "a.sav")
+	content := bytes.Repeat([]byte("save data "), 10000)
+	if err := os.WriteFile(infile, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCompressReader(infile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if r.Size() >= int64(len(content)) {
+		t.Errorf("compressed size %d is not smaller than %d", r.Size(), len(content))
+	}
+
+	outfile := filepath.Join(dir, "a.sav.download")
+	w := NewDecompressWriter(outfile)
+	// 전송처럼 조금씩 나눠서 씀
+	if _, err := io.CopyBuffer(w, r, make([]byte, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if w.Hash() != r.Hash() {
+		t.Errorf("hash = %s, want %s", w.Hash(), r.Hash())
+	}
+	if got, _ := os.ReadFile(outfile); !bytes.Equal(got, content) {
+		t.Errorf("decompressed content differs")
+	}
+}
+
+func TestDecompressWriterCorrupt(t *testing.T) {
+	w := NewDecompressWriter(filepath.Join(t.TempDir(), "a.sav.download"))
+	_, werr := w.Write([]byte("not gzip"))
+	if err := w.Close(); err == nil && werr == nil {
+		t.Errorf("corrupt input was accepted")
+	}
+}
+
+func TestDecompressWriterCloseWithError(t *testing.T) {
+	w := NewDecompressWriter(filepath.Join(t.TempDir(), "a.sav.download"))
+	aborted := errors.New("aborted")
+	if err := w.CloseWithError(aborted); err != aborted {
+		t.Errorf("CloseWithError = %v, want %v", err, aborted)
+	}
+	if err := w.Close(); err != aborted {
+		t.Errorf("second Close = %v, want the first result", err)
+	}
+}
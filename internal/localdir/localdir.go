@@ -0,0 +1,252 @@
+package localdir
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"scpsave/internal/storage"
+	"time"
+)
+
+// Backend 로컬 디렉터리(NAS 마운트, USB 드라이브 등)를 원격 저장소로 사용
+// 파일은 압축하지 않고 그대로 복사
+type Backend struct{}
+
+var _ storage.Backend = (*Backend)(nil)
+
+func NewBackend() *Backend {
+	return &Backend{}
+}
+
+func (b *Backend) Close() {}
+
+func (b *Backend) UploadFile(ctx context.Context, localPath, remotePath, hash string) error {
+	localPath = filepath.Clean(localPath)
+	remotePath = filepath.FromSlash(remotePath)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(remotePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", remotePath, err)
+	}
+
+	tempfile := remotePath + ".uploading"
+	copied, err := copyFile(localPath, tempfile)
+	if err != nil {
+		_ = os.Remove(tempfile)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, localPath)
+		}
+		return fmt.Errorf("failed to upload file %s to %s: %w", localPath, remotePath, err)
+	}
+	if hash != "" && copied != hash {
+		_ = os.Remove(tempfile)
+		return fmt.Errorf("%w: %s", storage.ErrHashMismatch, localPath)
+	}
+	if err := os.Rename(tempfile, remotePath); err != nil {
+		_ = os.Remove(tempfile)
+		return fmt.Errorf("failed to rename %s to %s: %w", tempfile, remotePath, err)
+	}
+	return nil
+}
+
+func (b *Backend) DownloadFile(ctx context.Context, remotePath, localPath string, modTime int64, hash string) error {
+	remotePath = filepath.FromSlash(remotePath)
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %s: %w", localPath, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create local directory for %s: %w", localPath, err)
+	}
+
+	tempfile := localPath + ".download"
+	copied, err := copyFile(remotePath, tempfile)
+	if err != nil {
+		_ = os.Remove(tempfile)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+		}
+		return fmt.Errorf("failed to download file from %s to %s: %w", remotePath, tempfile, err)
+	}
+	if hash != "" && copied != hash {
+		_ = os.Remove(tempfile)
+		return fmt.Errorf("%w: %s", storage.ErrHashMismatch, remotePath)
+	}
+	if err := os.Rename(tempfile, localPath); err != nil {
+		_ = os.Remove(tempfile)
+		return fmt.Errorf("failed to rename temporary file %s to %s: %w", tempfile, localPath, err)
+	}
+
+	modTimeTime := time.Unix(0, modTime)
+	if err := os.Chtimes(localPath, modTimeTime, modTimeTime); err != nil {
+		log.Printf("failed to set modification time for %s: %+v\n", localPath, err)
+	}
+	return nil
+}
+
+func (b *Backend) MoveRemoteFile(oldRemotePath, newRemotePath string) error {
+	oldRemotePath = filepath.FromSlash(oldRemotePath)
+	newRemotePath = filepath.FromSlash(newRemotePath)
+	if err := os.MkdirAll(filepath.Dir(newRemotePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", newRemotePath, err)
+	}
+	if err := os.Rename(oldRemotePath, newRemotePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, oldRemotePath)
+		}
+		return fmt.Errorf("failed to rename remote file %s: %w", oldRemotePath, err)
+	}
+	return nil
+}
+
+func (b *Backend) LinkRemoteFile(oldRemotePath, newRemotePath string) error {
+	oldRemotePath = filepath.FromSlash(oldRemotePath)
+	newRemotePath = filepath.FromSlash(newRemotePath)
+	if err := os.MkdirAll(filepath.Dir(newRemotePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", newRemotePath, err)
+	}
+	if err := os.Remove(newRemotePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace remote file %s: %w", newRemotePath, err)
+	}
+	if err := os.Link(oldRemotePath, newRemotePath); err == nil {
+		return nil
+	}
+
+	// FAT32 등 하드 링크를 지원하지 않는 파일 시스템
+	if _, err := copyFile(oldRemotePath, newRemotePath); err != nil {
+		_ = os.Remove(newRemotePath)
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, oldRemotePath)
+		}
+		return fmt.Errorf("failed to link remote file %s to %s: %w", oldRemotePath, newRemotePath, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteRemoteFile(remotePath string) error {
+	remotePath = filepath.FromSlash(remotePath)
+	if err := os.Remove(remotePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete remote file %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+func (b *Backend) DeleteRemoteDir(remoteDir string) error {
+	remoteDir = filepath.FromSlash(remoteDir)
+	if err := os.RemoveAll(remoteDir); err != nil {
+		return fmt.Errorf("failed to delete directory %s: %w", remoteDir, err)
+	}
+	return nil
+}
+
+func (b *Backend) CreateRemoteFile(remotePath string, data []byte) error {
+	remotePath = filepath.FromSlash(remotePath)
+	if err := os.MkdirAll(filepath.Dir(remotePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", remotePath, err)
+	}
+	f, err := os.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s", storage.ErrFileExists, remotePath)
+		}
+		return fmt.Errorf("failed to create file %s: %w", remotePath, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", remotePath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+func (b *Backend) ReadRemoteFile(remotePath string) ([]byte, error) {
+	remotePath = filepath.FromSlash(remotePath)
+	data, err := os.ReadFile(remotePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+		}
+		return nil, fmt.Errorf("failed to read file %s: %w", remotePath, err)
+	}
+	return data, nil
+}
+
+func (b *Backend) ListRemoteDir(remoteDir string) ([]*storage.FileInfo, error) {
+	remoteDir = filepath.FromSlash(remoteDir)
+	entries, err := os.ReadDir(remoteDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remoteDir)
+		}
+		return nil, fmt.Errorf("failed to list remote directory %s: %w", remoteDir, err)
+	}
+
+	infos := make([]*storage.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s in %s: %w", entry.Name(), remoteDir, err)
+		}
+		infos = append(infos, toFileInfo(info))
+	}
+	return infos, nil
+}
+
+func (b *Backend) StatRemoteFile(remotePath string) (*storage.FileInfo, error) {
+	remotePath = filepath.FromSlash(remotePath)
+	info, err := os.Stat(remotePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+		}
+		return nil, fmt.Errorf("failed to stat remote file %s: %w", remotePath, err)
+	}
+	return toFileInfo(info), nil
+}
+
+func toFileInfo(info os.FileInfo) *storage.FileInfo {
+	return &storage.FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+// copyFile src 를 dst 로 복사하고 복사한 내용의 SHA-512 를 반환
+func copyFile(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
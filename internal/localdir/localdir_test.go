@@ -0,0 +1,192 @@
+package localdir
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"scpsave/internal/storage"
+	"sort"
+	"testing"
+	"time"
+)
+
+func sha512Hex(content string) string {
+	sum := sha512.Sum512([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(t *testing.T, p, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, p string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.FromSlash(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUploadAndDownload(t *testing.T) {
+	b := NewBackend()
+	ctx := context.Background()
+	local := filepath.Join(t.TempDir(), "a.sav")
+	remoteRoot := filepath.ToSlash(t.TempDir())
+	remote := path.Join(remoteRoot, "save", "a.sav")
+	writeFile(t, local, "content")
+
+	if err := b.UploadFile(ctx, local, remote, sha512Hex("content")); err != nil {
+		t.Fatalf("UploadFile failed: %v", err)
+	}
+	if got := readFile(t, remote); got != "content" {
+		t.Errorf("uploaded %q", got)
+	}
+	if _, err := os.Stat(filepath.FromSlash(remote + ".uploading")); !os.IsNotExist(err) {
+		t.Errorf("temporary upload file was left: %v", err)
+	}
+
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	downloaded := filepath.Join(t.TempDir(), "dir", "a.sav")
+	if err := b.DownloadFile(ctx, remote, downloaded, modTime.UnixNano(), sha512Hex("content")); err != nil {
+		t.Fatalf("DownloadFile failed: %v", err)
+	}
+	if got := readFile(t, downloaded); got != "content" {
+		t.Errorf("downloaded %q", got)
+	}
+	if info, err := os.Stat(downloaded); err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("modification time was not set: %v, %v", info, err)
+	}
+}
+
+func TestHashMismatch(t *testing.T) {
+	b := NewBackend()
+	ctx := context.Background()
+	local := filepath.Join(t.TempDir(), "a.sav")
+	remote := path.Join(filepath.ToSlash(t.TempDir()), "a.sav")
+	writeFile(t, local, "content")
+
+	if err := b.UploadFile(ctx, local, remote, sha512Hex("other")); !errors.Is(err, storage.ErrHashMismatch) {
+		t.Errorf("UploadFile = %v, want ErrHashMismatch", err)
+	}
+	if _, err := b.StatRemoteFile(remote); !errors.Is(err, storage.ErrNoSuchFile) {
+		t.Errorf("file was uploaded despite the mismatch: %v", err)
+	}
+
+	writeFile(t, filepath.FromSlash(remote), "content")
+	downloaded := filepath.Join(t.TempDir(), "a.sav")
+	if err := b.DownloadFile(ctx, remote, downloaded, 0, sha512Hex("other")); !errors.Is(err, storage.ErrHashMismatch) {
+		t.Errorf("DownloadFile = %v, want ErrHashMismatch", err)
+	}
+	if _, err := os.Stat(downloaded); !os.IsNotExist(err) {
+		t.Errorf("file was downloaded despite the mismatch: %v", err)
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	b := NewBackend()
+	ctx := context.Background()
+	dir := filepath.ToSlash(t.TempDir())
+	missing := path.Join(dir, "missing")
+
+	checks := map[string]error{
+		"UploadFile":     b.UploadFile(ctx, filepath.FromSlash(missing), path.Join(dir, "a"), ""),
+		"DownloadFile":   b.DownloadFile(ctx, missing, filepath.Join(t.TempDir(), "a"), 0, ""),
+		"MoveRemoteFile": b.MoveRemoteFile(missing, path.Join(dir, "b")),
+		"LinkRemoteFile": b.LinkRemoteFile(missing, path.Join(dir, "c")),
+		"ReadRemoteFile": func() error { _, err := b.ReadRemoteFile(missing); return err }(),
+		"ListRemoteDir":  func() error { _, err := b.ListRemoteDir(missing); return err }(),
+		"StatRemoteFile": func() error { _, err := b.StatRemoteFile(missing); return err }(),
+	}
+	for name, err := range checks {
+		if !errors.Is(err, storage.ErrNoSuchFile) {
+			t.Errorf("%s = %v, want ErrNoSuchFile", name, err)
+		}
+	}
+	// 없는 것을 지우는 것은 오류가 아님
+	if err := b.DeleteRemoteFile(missing); err != nil {
+		t.Errorf("DeleteRemoteFile = %v", err)
+	}
+	if err := b.DeleteRemoteDir(missing); err != nil {
+		t.Errorf("DeleteRemoteDir = %v", err)
+	}
+}
+
+func TestCreateRemoteFileIsExclusive(t *testing.T) {
+	b := NewBackend()
+	p := path.Join(filepath.ToSlash(t.TempDir()), "lock", "a.lock")
+
+	if err := b.CreateRemoteFile(p, []byte("first")); err != nil {
+		t.Fatalf("CreateRemoteFile failed: %v", err)
+	}
+	if err := b.CreateRemoteFile(p, []byte("second")); !errors.Is(err, storage.ErrFileExists) {
+		t.Errorf("second CreateRemoteFile = %v, want ErrFileExists", err)
+	}
+	if data, err := b.ReadRemoteFile(p); err != nil || string(data) != "first" {
+		t.Errorf("ReadRemoteFile = %q, %v", data, err)
+	}
+}
+
+func TestMoveLinkListAndDelete(t *testing.T) {
+	b := NewBackend()
+	dir := filepath.ToSlash(t.TempDir())
+	writeFile(t, filepath.FromSlash(path.Join(dir, "a")), "a")
+
+	if err := b.MoveRemoteFile(path.Join(dir, "a"), path.Join(dir, "sub", "b")); err != nil {
+		t.Fatalf("MoveRemoteFile failed: %v", err)
+	}
+	if err := b.LinkRemoteFile(path.Join(dir, "sub", "b"), path.Join(dir, "sub", "c")); err != nil {
+		t.Fatalf("LinkRemoteFile failed: %v", err)
+	}
+	// 이미 있는 파일은 바꿈
+	writeFile(t, filepath.FromSlash(path.Join(dir, "sub", "d")), "old")
+	if err := b.LinkRemoteFile(path.Join(dir, "sub", "b"), path.Join(dir, "sub", "d")); err != nil {
+		t.Fatalf("LinkRemoteFile over an existing file failed: %v", err)
+	}
+	if got := readFile(t, path.Join(dir, "sub", "d")); got != "a" {
+		t.Errorf("linked file has %q", got)
+	}
+
+	infos, err := b.ListRemoteDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name != "sub" || !infos[0].IsDir {
+		t.Errorf("ListRemoteDir = %+v, want only sub/", infos)
+	}
+	infos, err = b.ListRemoteDir(path.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 3 || names[0] != "b" || names[1] != "c" || names[2] != "d" {
+		t.Errorf("ListRemoteDir(sub) = %v", names)
+	}
+
+	if err := b.DeleteRemoteFile(path.Join(dir, "sub", "b")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path.Join(dir, "sub", "c")); got != "a" {
+		t.Errorf("link lost its content after the original was deleted: %q", got)
+	}
+	if err := b.DeleteRemoteDir(path.Join(dir, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.StatRemoteFile(path.Join(dir, "sub")); !errors.Is(err, storage.ErrNoSuchFile) {
+		t.Errorf("directory still exists: %v", err)
+	}
+}
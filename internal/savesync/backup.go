@@ -0,0 +1,241 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"sort"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalBackup 원격 내용으로 로컬 파일을 덮어쓰거나 지우기 전에 남기는 사본
+// Files 는 백업할 때의 로컬 파일 목록, Archived 는 실제로 복사해 둔 파일
+type LocalBackup struct {
+	ID       string            `yaml:"id"`
+	Time     time.Time         `yaml:"time"`
+	Files    filelist.FileList `yaml:"files"`
+	Archived []string          `yaml:"archived"`
+}
+
+// backupLocalFiles relPaths 중 로컬에 있는 파일을 백업하고 오래된 백업을 정리
+// 백업할 파일이 없거나 백업을 끈 경우 nil 을 돌려줌
+func backupLocalFiles(game *config.GameConfig, mine filelist.FileList, relPaths []string) (*LocalBackup, error) {
+	backup, err := createLocalBackup(game, mine, relPaths)
+	if err != nil || backup == nil {
+		return backup, err
+	}
+	if err := pruneLocalBackups(game); err != nil {
+		log.Printf("[%s] failed to prune local backups: %+v\n", game.Name, err)
+	}
+	return backup, nil
+}
+
+// createLocalBackup 오래된 백업은 정리하지 않고 백업만 만듦
+func createLocalBackup(game *config.GameConfig, mine filelist.FileList, relPaths []string) (*LocalBackup, error) {
+	if config.Value.LocalBackups() <= 0 {
+		return nil, nil
+	}
+
+	var archived []string
+	for _, relPath := range relPaths {
+		if _, exists := mine[relPath]; exists {
+			archived = append(archived, relPath)
+		}
+	}
+	if len(archived) == 0 {
+		return nil, nil
+	}
+	sort.Strings(archived)
+
+	backup := &LocalBackup{
+		Time:     time.Now().UTC(),
+		Files:    mine,
+		Archived: archived,
+	}
+	id, err := newLocalBackupID(game, backup.Time)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to create local backup directory: %w", game.Name, err)
+	}
+	backup.ID = id
+
+	for _, relPath := range archived {
+		if err := copyLocalFile(game.LocalFilePath(relPath), game.LocalBackupFilePath(id, relPath)); err != nil {
+			return nil, fmt.Errorf("[%s] failed to back up local file %s: %w", game.Name, relPath, err)
+		}
+	}
+
+	// backup.yaml 을 마지막에 써서 완성된 백업만 목록에 나오도록 함
+	bt, err := yaml.Marshal(backup)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to marshal local backup %s: %w", game.Name, id, err)
+	}
+	if err := os.WriteFile(game.LocalBackupMetaPath(id), bt, 0644); err != nil {
+		return nil, fmt.Errorf("[%s] failed to write local backup %s: %w", game.Name, id, err)
+	}
+	log.Printf("[%s] backed up %d local files to %s\n", game.Name, len(archived), id)
+	return backup, nil
+}
+
+func newLocalBackupID(game *config.GameConfig, now time.Time) (string, error) {
+	if err := os.MkdirAll(game.LocalBackupDir(), 0755); err != nil {
+		return "", err
+	}
+	base := now.Format(snapshotTimeFormat)
+	id := base
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(game.LocalBackupDir(), id), 0755)
+		if err == nil {
+			return id, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		id = base + "-" + strconv.Itoa(i)
+	}
+}
+
+func pruneLocalBackups(game *config.GameConfig) error {
+	backups, err := ListLocalBackups(game)
+	if err != nil {
+		return err
+	}
+	for len(backups) > config.Value.LocalBackups() {
+		if err := os.RemoveAll(filepath.Join(game.LocalBackupDir(), backups[0].ID)); err != nil {
+			return fmt.Errorf("failed to remove local backup %s: %w", backups[0].ID, err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func ListLocalBackups(game *config.GameConfig) ([]*LocalBackup, error) {
+	entries, err := os.ReadDir(game.LocalBackupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[%s] failed to list local backups: %w", game.Name, err)
+	}
+
+	var backups []*LocalBackup
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backup, err := loadLocalBackup(game, entry.Name())
+		if err != nil {
+			continue // 만드는 중이거나 실패한 백업
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.Before(backups[j].Time)
+		}
+		return backups[i].ID < backups[j].ID
+	})
+	return backups, nil
+}
+
+func loadLocalBackup(game *config.GameConfig, id string) (*LocalBackup, error) {
+	bt, err := os.ReadFile(game.LocalBackupMetaPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var backup LocalBackup
+	if err := yaml.Unmarshal(bt, &backup); err != nil {
+		return nil, err
+	}
+	backup.ID = id
+	return &backup, nil
+}
+
+// RollbackLocalBackup 로컬 파일을 백업할 때의 상태로 되돌림
+// 백업 뒤에 생긴 파일은 지우고, 되돌리기 전의 상태도 새 백업으로 남김
+// 되돌릴 백업이 먼저 정리되지 않도록 오래된 백업은 되돌린 뒤에 정리함
+// base.yaml 은 건드리지 않으므로 다음 동기화 때 되돌린 내용이 로컬 변경으로 처리됨
+func RollbackLocalBackup(game *config.GameConfig, id string) error {
+	backup, err := loadLocalBackup(game, id)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("[%s] local backup %s not found", game.Name, id)
+		}
+		return fmt.Errorf("[%s] failed to load local backup %s: %w", game.Name, id, err)
+	}
+
+	mine, err := makeLocalFileList(context.Background(), game)
+	if err != nil {
+		return err
+	}
+
+	var created []string
+	for relPath := range mine {
+		if _, exists := backup.Files[relPath]; !exists {
+			created = append(created, relPath)
+		}
+	}
+	if _, err := createLocalBackup(game, mine, append(created, backup.Archived...)); err != nil {
+		return err
+	}
+
+	log.Printf("[%s] rolling back local files to %s...\n", game.Name, id)
+	for _, relPath := range backup.Archived {
+		log.Printf("[%s] restoring local file %s\n", game.Name, relPath)
+		if err := copyLocalFile(game.LocalBackupFilePath(id, relPath), game.LocalFilePath(relPath)); err != nil {
+			return fmt.Errorf("[%s] failed to restore local file %s: %w", game.Name, relPath, err)
+		}
+	}
+	for _, relPath := range created {
+		log.Printf("[%s] deleting local file %s\n", game.Name, relPath)
+		if err := os.Remove(game.LocalFilePath(relPath)); err != nil {
+			return fmt.Errorf("[%s] failed to delete local file %s: %w", game.Name, relPath, err)
+		}
+	}
+
+	if err := pruneLocalBackups(game); err != nil {
+		log.Printf("[%s] failed to prune local backups: %+v\n", game.Name, err)
+	}
+	return nil
+}
+
+// copyLocalFile 파일을 복사하고 수정 시간도 그대로 맞춤
+func copyLocalFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open local file %s: %w", src, err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat local file %s: %w", src, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create local directory for %s: %w", dst, err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create local file %s: %w", dst, err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to copy local file from %s to %s: %w", src, dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close local file %s: %w", dst, err)
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", dst, err)
+	}
+	return nil
+}
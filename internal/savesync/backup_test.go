@@ -0,0 +1,88 @@
+package savesync
+
+import (
+	"os"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"testing"
+)
+
+func backupTestFiles(t *testing.T, game *config.GameConfig, content string) filelist.FileList {
+	t.Helper()
+	writeTestFile(t, game.LocalFilePath("a.sav"), content)
+	return filelist.FileList{"a.sav": fileMeta(content, 1)}
+}
+
+func TestBackupLocalFilesKeepsLocalBackupCount(t *testing.T) {
+	game, _ := newTestGame(t)
+	count := 2
+	config.Value.LocalBackupCount = &count
+
+	for _, content := range []string{"v1", "v2", "v3"} {
+		mine := backupTestFiles(t, game, content)
+		backup, err := backupLocalFiles(game, mine, []string{"a.sav", "missing.sav"})
+		if err != nil || backup == nil {
+			t.Fatalf("backupLocalFiles = %v, %v", backup, err)
+		}
+		if len(backup.Archived) != 1 || backup.Archived[0] != "a.sav" {
+			t.Errorf("archived = %v, want [a.sav]", backup.Archived)
+		}
+	}
+
+	backups, err := ListLocalBackups(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("%d backups are kept, want 2", len(backups))
+	}
+	if data, _ := os.ReadFile(game.LocalBackupFilePath(backups[0].ID, "a.sav")); string(data) != "v2" {
+		t.Errorf("oldest kept backup has %q, want v2", data)
+	}
+}
+
+func TestBackupLocalFilesDisabled(t *testing.T) {
+	for _, count := range []int{0, -1} {
+		game, _ := newTestGame(t)
+		config.Value.LocalBackupCount = &count
+
+		mine := backupTestFiles(t, game, "v1")
+		backup, err := backupLocalFiles(game, mine, []string{"a.sav"})
+		if err != nil || backup != nil {
+			t.Errorf("local_backup_count %d: backupLocalFiles = %v, %v, want no backup", count, backup, err)
+		}
+		assertExists(t, game.LocalBackupDir(), false)
+	}
+}
+
+func TestRollbackLocalBackup(t *testing.T) {
+	game, _ := newTestGame(t)
+
+	mine := backupTestFiles(t, game, "before")
+	backup, err := backupLocalFiles(game, mine, []string{"a.sav"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, game.LocalFilePath("a.sav"), "after")
+	writeTestFile(t, game.LocalFilePath("new.sav"), "created later")
+
+	if err := RollbackLocalBackup(game, backup.ID); err != nil {
+		t.Fatalf("RollbackLocalBackup failed: %v", err)
+	}
+	if data, _ := os.ReadFile(game.LocalFilePath("a.sav")); string(data) != "before" {
+		t.Errorf("a.sav = %q, want the backup", data)
+	}
+	assertExists(t, game.LocalFilePath("new.sav"), false)
+
+	// 되돌리기 전의 상태도 백업으로 남음
+	backups, err := ListLocalBackups(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("%d backups, want 2", len(backups))
+	}
+	if data, _ := os.ReadFile(game.LocalBackupFilePath(backups[1].ID, "new.sav")); string(data) != "created later" {
+		t.Errorf("new.sav was not backed up before rollback: %q", data)
+	}
+}
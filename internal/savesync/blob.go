@@ -0,0 +1,227 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/storage"
+	"sort"
+	"time"
+)
+
+// 저장 파일은 blobs/<game>/ 아래에 내용의 SHA-512 이름으로 한 번만 올리고
+// 세대에는 경로와 해시를 담은 remote.yaml 만 둠
+// 세대를 정리할 때 남은 세대에서 참조하지 않는 blob 을 지움
+
+// downloadBlob 파일을 blob 에서 받음, blob 이 없으면 blob 을 쓰기 전 방식의 경로에서 받음
+func downloadBlob(ctx context.Context, game *config.GameConfig, backend storage.Backend, generation, relPath string, metadata *filelist.FileMetadata) error {
+	localPath := game.LocalFileDownloadPath(relPath)
+	if filelist.IsHash(metadata.Hash) {
+		err := backend.DownloadFile(ctx, game.RemoteBlobPath(metadata.Hash), localPath, metadata.ModifiedTime, metadata.Hash)
+		if !errors.Is(err, storage.ErrNoSuchFile) {
+			return err
+		}
+	}
+	return backend.DownloadFile(ctx, remoteFilePath(game, generation, relPath), localPath, metadata.ModifiedTime, metadata.Hash)
+}
+
+// blobSources 내용마다 올릴 때 읽을 파일, 같은 내용이 여러 곳에 있으면 이름이 앞서는 파일
+func blobSources(game *config.GameConfig, files filelist.FileList) (map[string]string, error) {
+	sources := make(map[string]string, len(files))
+	for relPath, metadata := range files {
+		if !filelist.IsHash(metadata.Hash) {
+			return nil, fmt.Errorf("[%s] invalid hash of file %s", game.Name, relPath)
+		}
+		if prev, exists := sources[metadata.Hash]; exists && prev < relPath {
+			continue
+		}
+		sources[metadata.Hash] = relPath
+	}
+	return sources, nil
+}
+
+func sortedValues(sources map[string]string) []string {
+	values := make([]string, 0, len(sources))
+	for _, value := range sources {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// uploadBlobs sources 중 원격에 blob 이 없는 것만 올림
+// 이전 세대에 경로 단위로 저장된 파일은 전송 없이 링크해서 blob 으로 만듦
+func uploadBlobs(
+	ctx context.Context,
+	game *config.GameConfig,
+	backend storage.Backend,
+	sources map[string]string,
+	remote filelist.FileList,
+	generation string,
+) error {
+	hashes := make([]string, 0, len(sources))
+	for hash := range sources {
+		hashes = append(hashes, hash)
+	}
+
+	return runParallel(game.ParallelTransfers, hashes, func(hash string) error {
+		relPath := sources[hash]
+		blobPath := game.RemoteBlobPath(hash)
+		if _, err := backend.StatRemoteFile(blobPath); err == nil {
+			return nil
+		} else if !errors.Is(err, storage.ErrNoSuchFile) {
+			return fmt.Errorf("[%s] failed to check remote blob of %s: %w", game.Name, relPath, err)
+		}
+
+		if metadata, exists := remote[relPath]; exists && metadata.Hash == hash {
+			err := backend.LinkRemoteFile(remoteFilePath(game, generation, relPath), blobPath)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, storage.ErrNoSuchFile) {
+				return fmt.Errorf("[%s] failed to link remote file %s: %w", game.Name, relPath, err)
+			}
+		}
+
+		log.Printf("[%s] uploading file %s...", game.Name, relPath)
+		uploadPath := game.RemoteFileUploadPath(relPath)
+		if err := backend.UploadFile(ctx, game.LocalFilePath(relPath), uploadPath, hash); err != nil {
+			return fmt.Errorf("[%s] failed to upload file %s: %w", game.Name, relPath, err)
+		}
+		if err := backend.MoveRemoteFile(uploadPath, blobPath); err != nil {
+			return fmt.Errorf("[%s] failed to move remote file %s: %w", game.Name, uploadPath, err)
+		}
+		return nil
+	})
+}
+
+// loadSnapshotMeta 세대의 remote.yaml, 세대는 바뀌지 않으므로 working/ 에 캐시
+func loadSnapshotMeta(ctx context.Context, game *config.GameConfig, backend storage.Backend, id string) (filelist.FileList, error) {
+	cachePath := game.SnapshotMetaCachePath(id)
+	files, err := filelist.LoadFileList(cachePath)
+	if err != nil || files == nil {
+		if err := backend.DownloadFile(ctx, game.RemoteSnapshotMetaPath(id), cachePath, time.Now().UnixNano(), ""); err != nil {
+			return nil, err
+		}
+		if files, err = filelist.LoadFileList(cachePath); err != nil {
+			return nil, err
+		}
+	}
+	if err := files.Validate(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// collectGarbage 게임의 history_count 보다 오래된 세대를 지우고, 지운 세대만 참조하던 blob 을 지움
+// 현재 세대는 오래되었더라도 남김
+func collectGarbage(ctx context.Context, game *config.GameConfig, backend storage.Backend, current string) error {
+	if err := checkRemoteLock(ctx); err != nil {
+		return err
+	}
+	// 세대를 쓰기 전 방식의 파일은 현재 세대가 생긴 뒤에는 필요 없음
+	if _, err := backend.StatRemoteFile(game.RemoteMetaFileRemotePath()); err == nil {
+		if err := backend.DeleteRemoteDir(game.RemoteSaveDir()); err != nil {
+			return err
+		}
+		if err := backend.DeleteRemoteFile(game.RemoteMetaFileRemotePath()); err != nil {
+			return err
+		}
+		log.Printf("[%s] deleted files of the old remote layout\n", game.Name)
+	}
+
+	snapshots, err := ListSnapshots(ctx, game)
+	if err != nil {
+		return err
+	}
+
+	var live, pruned []*Snapshot
+	for i, snapshot := range snapshots {
+		if snapshot.ID == current || i >= len(snapshots)-game.HistoryCount {
+			live = append(live, snapshot)
+		} else {
+			pruned = append(pruned, snapshot)
+		}
+	}
+	if len(pruned) == 0 {
+		return nil
+	}
+
+	refs, err := blobRefs(ctx, game, backend, live)
+	if err != nil {
+		return err
+	}
+
+	for _, snapshot := range pruned {
+		if err := checkRemoteLock(ctx); err != nil {
+			return err
+		}
+		files, err := loadSnapshotMeta(ctx, game, backend, snapshot.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load generation %s: %w", snapshot.ID, err)
+		}
+
+		// remote.yaml 을 먼저 지워서 목록에서 빠지게 한 뒤 정리
+		if err := backend.DeleteRemoteFile(game.RemoteSnapshotMetaPath(snapshot.ID)); err != nil {
+			return fmt.Errorf("failed to delete generation %s: %w", snapshot.ID, err)
+		}
+		if err := backend.DeleteRemoteDir(path.Join(game.RemoteHistoryDir(), snapshot.ID)); err != nil {
+			return fmt.Errorf("failed to delete generation %s: %w", snapshot.ID, err)
+		}
+		_ = os.Remove(game.SnapshotMetaCachePath(snapshot.ID))
+		log.Printf("[%s] deleted old generation %s\n", game.Name, snapshot.ID)
+
+		if err := deleteUnreferencedBlobs(game, backend, files, refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUncommittedBlobs 커밋되지 못한 세대가 올린 blob 중 남은 세대에서 참조하지 않는 것을 지움
+func deleteUncommittedBlobs(ctx context.Context, game *config.GameConfig, backend storage.Backend, files filelist.FileList) error {
+	snapshots, err := ListSnapshots(ctx, game)
+	if err != nil {
+		return err
+	}
+	refs, err := blobRefs(ctx, game, backend, snapshots)
+	if err != nil {
+		return err
+	}
+	return deleteUnreferencedBlobs(game, backend, files, refs)
+}
+
+// blobRefs 세대들의 blob 마다 참조하는 파일 수
+func blobRefs(ctx context.Context, game *config.GameConfig, backend storage.Backend, snapshots []*Snapshot) (map[string]int, error) {
+	refs := make(map[string]int)
+	for _, snapshot := range snapshots {
+		files, err := loadSnapshotMeta(ctx, game, backend, snapshot.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load generation %s: %w", snapshot.ID, err)
+		}
+		for _, metadata := range files {
+			refs[metadata.Hash]++
+		}
+	}
+	return refs, nil
+}
+
+// deleteUnreferencedBlobs files 의 blob 중 refs 에서 참조하지 않는 것을 지움
+// 지운 blob 은 refs 에 -1 로 표시해서 같은 내용을 다시 지우지 않음
+func deleteUnreferencedBlobs(game *config.GameConfig, backend storage.Backend, files filelist.FileList, refs map[string]int) error {
+	for _, metadata := range files {
+		if refs[metadata.Hash] != 0 || !filelist.IsHash(metadata.Hash) {
+			continue
+		}
+		refs[metadata.Hash] = -1
+		if err := backend.DeleteRemoteFile(game.RemoteBlobPath(metadata.Hash)); err != nil {
+			return fmt.Errorf("failed to delete blob %s: %w", metadata.Hash, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package savesync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"testing"
+	"time"
+)
+
+// syncTestFile 로컬 파일을 content 로 바꾸고 동기화
+func syncTestFile(t *testing.T, ctx context.Context, game *config.GameConfig, relPath, content string) {
+	t.Helper()
+	writeTestFile(t, game.LocalFilePath(relPath), content)
+	if err := SyncGame(ctx, game, false); err != nil {
+		t.Fatalf("SyncGame failed: %v", err)
+	}
+}
+
+func contentHash(t *testing.T, game *config.GameConfig, relPath string) string {
+	t.Helper()
+	files, err := filelist.MakeFileList(game.LocalDir, game.FileMatcher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files[relPath].Hash
+}
+
+func TestSyncGameSwitchesGeneration(t *testing.T) {
+	game, backend := newTestGame(t)
+	ctx := newTestContext(backend)
+
+	syncTestFile(t, ctx, game, "a.sav", "first")
+	first, err := readRemoteGeneration(game, backend)
+	if err != nil || first == "" {
+		t.Fatalf("no remote generation after sync: %q, %v", first, err)
+	}
+	// 동기화 상태 파일과 이름이 같은 저장 파일
+	writeTestFile(t, game.LocalFilePath("journal.yaml"), "save named journal")
+	writeTestFile(t, game.LocalFilePath("base.yaml"), "save named base")
+	syncTestFile(t, ctx, game, "a.sav", "second")
+	second, err := readRemoteGeneration(game, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatalf("generation was not switched from %s", first)
+	}
+	if local, _ := loadLocalGeneration(game); local != second {
+		t.Errorf("local generation = %s, want %s", local, second)
+	}
+	assertExists(t, game.RemoteBlobPath(contentHash(t, game, "a.sav")), true)
+
+	// 다른 컴퓨터에서 받으면 현재 세대의 내용을 받음
+	other := *game
+	other.LocalDir = t.TempDir()
+	config.StateDir = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(config.WorkingDir(), other.AltName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := SyncGame(ctx, &other, false); err != nil {
+		t.Fatalf("SyncGame on the other machine failed: %v", err)
+	}
+	for relPath, want := range map[string]string{"a.sav": "second", "journal.yaml": "save named journal", "base.yaml": "save named base"} {
+		if data, _ := os.ReadFile(other.LocalFilePath(relPath)); string(data) != want {
+			t.Errorf("other machine has %q in %s, want %q", data, relPath, want)
+		}
+	}
+	if hasSyncJournal(&other) {
+		t.Errorf("a save file was taken for the sync journal")
+	}
+}
+
+func TestCollectGarbageKeepsHistoryCountOfGame(t *testing.T) {
+	game, backend := newTestGame(t)
+	ctx := newTestContext(backend)
+	config.Value.HistoryCount = 20
+	game.HistoryCount = 2
+
+	writeTestFile(t, game.LocalFilePath("shared.sav"), "unchanged")
+	var hashes []string
+	for _, content := range []string{"v1", "v2", "v3"} {
+		syncTestFile(t, ctx, game, "a.sav", content)
+		hashes = append(hashes, contentHash(t, game, "a.sav"))
+	}
+
+	snapshots, err := ListSnapshots(ctx, game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("%d generations are kept, want 2", len(snapshots))
+	}
+	if !snapshots[1].Current {
+		t.Errorf("the latest generation is not current")
+	}
+	assertExists(t, game.RemoteBlobPath(hashes[0]), false)
+	assertExists(t, game.RemoteBlobPath(hashes[1]), true)
+	assertExists(t, game.RemoteBlobPath(hashes[2]), true)
+	assertExists(t, game.RemoteBlobPath(contentHash(t, game, "shared.sav")), true)
+}
+
+func TestCollectGarbageKeepsCurrentGeneration(t *testing.T) {
+	game, backend := newTestGame(t)
+	ctx := newTestContext(backend)
+	game.HistoryCount = 1
+
+	syncTestFile(t, ctx, game, "a.sav", "v1")
+	current, err := readRemoteGeneration(game, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := loadSnapshotMeta(ctx, game, backend, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 예전 세대로 되돌린 경우처럼 더 새로운 세대가 있어도 현재 세대는 남김
+	snapshot, _ := parseSnapshotID(current)
+	newer := newSnapshotID(snapshot.Time.Add(time.Hour))
+	saveTestFileList(t, game.RemoteSnapshotMetaPath(newer), filelist.FileList{})
+	if err := collectGarbage(ctx, game, backend, current); err != nil {
+		t.Fatalf("collectGarbage failed: %v", err)
+	}
+	assertExists(t, game.RemoteSnapshotMetaPath(current), true)
+	assertExists(t, game.RemoteSnapshotMetaPath(newer), true)
+	assertExists(t, game.RemoteBlobPath(files["a.sav"].Hash), true)
+}
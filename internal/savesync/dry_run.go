@@ -0,0 +1,114 @@
+package savesync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/storage"
+	"sort"
+)
+
+type contextDryRunKey struct{}
+
+// NewContextWithDryRun 동기화할 내용만 출력하고 로컬, 원격, base.yaml 은 건드리지 않음
+func NewContextWithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextDryRunKey{}, true)
+}
+
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(contextDryRunKey{}).(bool)
+	return dryRun
+}
+
+// dryRunSyncGame SyncGame 과 같은 방법으로 방향을 정하고 할 일을 출력
+// 잠금을 잡지 않고, working/ 에는 아무것도 쓰지 않도록 원격 파일 목록은 시스템 임시 디렉터리로 받음
+func dryRunSyncGame(ctx context.Context, game *config.GameConfig, backend storage.Backend) error {
+	if hasSyncJournal(game) {
+		// 정리하면 결과가 달라지므로 아래 내용은 정리하기 전 기준
+		log.Printf("[%s] (dry run) an interrupted sync would be recovered first\n", game.Name)
+	}
+
+	base, err := filelist.LoadFileList(game.BaseMetaFilePath())
+	if err != nil {
+		return fmt.Errorf("[%s] failed to load base file list: %w", game.Name, err)
+	}
+	mine, err := makeLocalFileList(ctx, game)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := os.MkdirTemp("", "scpsave-dry-run-")
+	if err != nil {
+		return fmt.Errorf("[%s] failed to create temporary directory: %w", game.Name, err)
+	}
+	defer os.RemoveAll(tempDir)
+	remote, generation, err := downloadRemoteMeta(ctx, game, backend, filepath.Join(tempDir, "remote.yaml"))
+	if err != nil {
+		return err
+	}
+
+	if generation != "" {
+		log.Printf("[%s] (dry run) remote generation %s\n", game.Name, generation)
+	}
+	merged, conflicts := mergeFileLists(base, mine, remote)
+
+	uploads, remoteDeletes := merged.Diff(remote)
+	downloads, localDeletes := merged.Diff(mine)
+	for _, relPath := range conflicts {
+		// 어느 쪽을 고를지 모르므로 양쪽을 모두 보여줌
+		delete(uploads, relPath)
+		delete(remoteDeletes, relPath)
+		delete(downloads, relPath)
+		delete(localDeletes, relPath)
+	}
+
+	switch {
+	case len(conflicts) > 0:
+		log.Printf("[%s] (dry run) direction: conflict, %d files need to be resolved\n", game.Name, len(conflicts))
+	case len(uploads)+len(remoteDeletes) > 0 && len(downloads)+len(localDeletes) > 0:
+		log.Printf("[%s] (dry run) direction: both\n", game.Name)
+	case len(uploads)+len(remoteDeletes) > 0:
+		log.Printf("[%s] (dry run) direction: local to remote\n", game.Name)
+	case len(downloads)+len(localDeletes) > 0:
+		log.Printf("[%s] (dry run) direction: remote to local\n", game.Name)
+	default:
+		log.Printf("[%s] (dry run) nothing to sync\n", game.Name)
+	}
+
+	for _, relPath := range sortedRelPaths(uploads) {
+		log.Printf("[%s] (dry run) upload %s (%d bytes)\n", game.Name, relPath, uploads[relPath].Size)
+	}
+	for _, relPath := range sortedRelPaths(remoteDeletes) {
+		log.Printf("[%s] (dry run) delete remote %s (%d bytes)\n", game.Name, relPath, remoteDeletes[relPath].Size)
+	}
+	for _, relPath := range sortedRelPaths(downloads) {
+		log.Printf("[%s] (dry run) download %s (%d bytes)\n", game.Name, relPath, downloads[relPath].Size)
+	}
+	for _, relPath := range sortedRelPaths(localDeletes) {
+		log.Printf("[%s] (dry run) delete local %s (%d bytes)\n", game.Name, relPath, localDeletes[relPath].Size)
+	}
+	for _, relPath := range conflicts {
+		log.Printf("[%s] (dry run) conflict %s (local: %s, remote: %s)\n", game.Name, relPath, describeSize(mine[relPath]), describeSize(remote[relPath]))
+	}
+	return nil
+}
+
+func sortedRelPaths(files filelist.FileList) []string {
+	relPaths := make([]string, 0, len(files))
+	for relPath := range files {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+	return relPaths
+}
+
+func describeSize(metadata *filelist.FileMetadata) string {
+	if metadata == nil {
+		return "deleted"
+	}
+	return fmt.Sprintf("%d bytes", metadata.Size)
+}
@@ -0,0 +1,70 @@
+package savesync
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"sort"
+	"testing"
+	"time"
+)
+
+// listTree dir 아래의 모든 파일과 디렉터리
+func listTree(t *testing.T, dir string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestDryRunWritesNothing(t *testing.T) {
+	game, backend := newTestGame(t)
+	ctx := newTestContext(backend)
+	writeTestFile(t, game.LocalFilePath("a.sav"), "local")
+
+	// 원격에 세대를 하나 만들어 둠
+	if err := SyncGame(ctx, game, false); err != nil {
+		t.Fatalf("SyncGame failed: %v", err)
+	}
+	// 해시 캐시에 들어가도록 수정 시간을 예전으로 돌림
+	writeTestFile(t, game.LocalFilePath("b.sav"), "new")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(game.LocalFilePath("b.sav"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	working := listTree(t, config.StateDir)
+	remote := listTree(t, filepath.FromSlash(game.RemoteRoot))
+	if err := SyncGame(NewContextWithDryRun(ctx), game, false); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if got := listTree(t, config.StateDir); !equalStrings(got, working) {
+		t.Errorf("dry run changed the state directory:\n%v\nwant\n%v", got, working)
+	}
+	if got := listTree(t, filepath.FromSlash(game.RemoteRoot)); !equalStrings(got, remote) {
+		t.Errorf("dry run changed the remote:\n%v\nwant\n%v", got, remote)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/storage"
+	"strings"
+	"time"
+)
+
+// 업로드할 때마다 history 아래에 파일과 remote.yaml 을 모두 갖춘 새 세대(스냅샷)를 만들고
+// meta/<game>/current 를 rename 으로 바꿔서 한 번에 반영함
+// current 가 없으면 세대를 쓰기 전의 save/, meta/remote.yaml 을 현재 상태로 봄 (세대 ID "")
+
+func remoteMetaPath(game *config.GameConfig, generation string) string {
+	if generation == "" {
+		return game.RemoteMetaFileRemotePath()
+	}
+	return game.RemoteSnapshotMetaPath(generation)
+}
+
+func remoteFilePath(game *config.GameConfig, generation, relPath string) string {
+	if generation == "" {
+		return game.RemoteFilePath(relPath)
+	}
+	return game.RemoteSnapshotFilePath(generation, relPath)
+}
+
+func parseGeneration(game *config.GameConfig, data []byte) (string, error) {
+	generation := strings.TrimSpace(string(data))
+	if _, ok := parseSnapshotID(generation); !ok {
+		return "", fmt.Errorf("[%s] invalid remote generation %q", game.Name, generation)
+	}
+	return generation, nil
+}
+
+func readRemoteGeneration(game *config.GameConfig, backend storage.Backend) (string, error) {
+	data, err := backend.ReadRemoteFile(game.RemoteGenerationPath())
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return "", nil
+		}
+		return "", fmt.Errorf("[%s] failed to read remote generation: %w", game.Name, err)
+	}
+	return parseGeneration(game, data)
+}
+
+// switchRemoteGeneration 원격의 현재 세대를 바꿈, rename 한 번이라 다른 쪽은 이전이나 새 세대만 보게 됨
+func switchRemoteGeneration(game *config.GameConfig, backend storage.Backend, generation string) error {
+	uploadPath := game.RemoteGenerationUploadPath()
+	_ = backend.DeleteRemoteFile(uploadPath)
+	if err := backend.CreateRemoteFile(uploadPath, []byte(generation+"\n")); err != nil {
+		return fmt.Errorf("[%s] failed to write remote generation: %w", game.Name, err)
+	}
+	if err := backend.MoveRemoteFile(uploadPath, game.RemoteGenerationPath()); err != nil {
+		return fmt.Errorf("[%s] failed to switch remote generation to %s: %w", game.Name, generation, err)
+	}
+	return saveLocalGeneration(game, generation)
+}
+
+// downloadRemoteMeta 현재 세대의 파일 목록을 localPath 로 받음
+func downloadRemoteMeta(ctx context.Context, game *config.GameConfig, backend storage.Backend, localPath string) (filelist.FileList, string, error) {
+	generation, err := readRemoteGeneration(game, backend)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var remote filelist.FileList
+	err = backend.DownloadFile(ctx, remoteMetaPath(game, generation), localPath, time.Now().UnixNano(), "")
+	if err == nil {
+		remote, err = filelist.LoadFileList(localPath)
+		if err == nil {
+			err = remote.Validate()
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("[%s] failed to load remote file list: %w", game.Name, err)
+		}
+	} else if !errors.Is(err, storage.ErrNoSuchFile) {
+		return nil, "", fmt.Errorf("[%s] failed to download remote file list: %w", game.Name, err)
+	}
+	return remote, generation, nil
+}
+
+func loadLocalGeneration(game *config.GameConfig) (string, error) {
+	data, err := os.ReadFile(game.GenerationFileLocalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("[%s] failed to read local generation: %w", game.Name, err)
+	}
+	if len(data) == 0 {
+		return "", nil
+	}
+	return parseGeneration(game, data)
+}
+
+func saveLocalGeneration(game *config.GameConfig, generation string) error {
+	if err := os.MkdirAll(filepath.Dir(game.GenerationFileLocalPath()), 0755); err != nil {
+		return fmt.Errorf("[%s] failed to create working directory: %w", game.Name, err)
+	}
+	if err := os.WriteFile(game.GenerationFileLocalPath(), []byte(generation), 0644); err != nil {
+		return fmt.Errorf("[%s] failed to save local generation: %w", game.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/storage"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+var (
+	reSnapshotHost      = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+	reSnapshotValidHost = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// Snapshot 업로드할 때마다 원격에 만드는 세대, 저장 파일 전체와 remote.yaml 을 가짐
+// ID 는 "<UTC 시간>_<업로드한 컴퓨터>" 형식, 같은 초에 만든 세대가 있으면 시간 뒤에 "-<번호>" 를 붙임
+type Snapshot struct {
+	ID       string
+	Time     time.Time
+	Seq      int
+	Hostname string
+	Current  bool
+}
+
+func newSnapshotID(now time.Time) string {
+	return snapshotID(now, 1)
+}
+
+// snapshotID seq 번째로 만든 같은 시간의 세대 ID, 첫 번째는 번호를 붙이지 않음
+func snapshotID(now time.Time, seq int) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+	hostname = reSnapshotHost.ReplaceAllString(hostname, "")
+	if hostname == "" {
+		hostname = "unknown"
+	}
+	timestamp := now.UTC().Format(snapshotTimeFormat)
+	if seq > 1 {
+		timestamp += "-" + strconv.Itoa(seq)
+	}
+	return timestamp + "_" + hostname
+}
+
+func parseSnapshotID(id string) (*Snapshot, bool) {
+	timestamp, hostname, ok := strings.Cut(id, "_")
+	if !ok || !reSnapshotValidHost.MatchString(hostname) || hostname == "." || hostname == ".." {
+		return nil, false
+	}
+	seq := 1
+	if base, suffix, hasSeq := strings.Cut(timestamp, "-"); hasSeq {
+		n, err := strconv.Atoi(suffix)
+		if err != nil || n < 2 || strconv.Itoa(n) != suffix {
+			return nil, false
+		}
+		timestamp, seq = base, n
+	}
+	t, err := time.Parse(snapshotTimeFormat, timestamp)
+	if err != nil {
+		return nil, false
+	}
+	return &Snapshot{ID: id, Time: t, Seq: seq, Hostname: hostname}, true
+}
+
+func ListSnapshots(ctx context.Context, game *config.GameConfig) ([]*Snapshot, error) {
+	backend, err := gameBackend(ctx, game)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := backend.ListRemoteDir(game.RemoteHistoryDir())
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[%s] failed to list snapshots: %w", game.Name, err)
+	}
+
+	generation, err := readRemoteGeneration(game, backend)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for _, info := range infos {
+		if !info.IsDir {
+			continue
+		}
+		snapshot, ok := parseSnapshotID(info.Name)
+		if !ok {
+			continue
+		}
+		if _, err := backend.StatRemoteFile(game.RemoteSnapshotMetaPath(snapshot.ID)); err != nil {
+			continue // 만드는 중이거나 실패한 스냅샷
+		}
+		snapshot.Current = snapshot.ID == generation
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// sortSnapshots 만든 순서로 정렬, 번호가 두 자리가 되면 ID 의 문자열 순서와 다름
+func sortSnapshots(snapshots []*Snapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		a, b := snapshots[i], snapshots[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if a.Seq != b.Seq {
+			return a.Seq < b.Seq
+		}
+		return a.ID < b.ID
+	})
+}
+
+// RestoreSnapshot 스냅샷을 원격의 현재 세대로 되돌리고 로컬에도 받음
+func RestoreSnapshot(ctx context.Context, game *config.GameConfig, id string) error {
+	backend, err := gameBackend(ctx, game)
+	if err != nil {
+		return err
+	}
+	if _, ok := parseSnapshotID(id); !ok {
+		return fmt.Errorf("[%s] invalid snapshot %s", game.Name, id)
+	}
+
+	lock, err := acquireRemoteLock(ctx, game, backend)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	ctx = lock.ctx
+
+	staged, err := recoverSyncJournal(ctx, game, backend)
+	if err != nil {
+		return err
+	}
+
+	snapshotMetaLocal := game.SnapshotMetaFileLocalPath()
+	if err := backend.DownloadFile(ctx, game.RemoteSnapshotMetaPath(id), snapshotMetaLocal, time.Now().UnixNano(), ""); err != nil {
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return fmt.Errorf("[%s] snapshot %s not found", game.Name, id)
+		}
+		return fmt.Errorf("[%s] failed to download snapshot %s metadata: %w", game.Name, id, err)
+	}
+	snapshot, err := filelist.LoadFileList(snapshotMetaLocal)
+	if err == nil {
+		err = snapshot.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("[%s] failed to load snapshot %s metadata: %w", game.Name, id, err)
+	}
+	defer os.Remove(snapshotMetaLocal)
+
+	log.Printf("[%s] restoring snapshot %s on remote...\n", game.Name, id)
+	if err := checkRemoteLock(ctx); err != nil {
+		return err
+	}
+	if err := switchRemoteGeneration(game, backend, id); err != nil {
+		return err
+	}
+	if err := snapshot.Save(game.RemoteMetaFileLocalPath()); err != nil {
+		return fmt.Errorf("[%s] failed to save remote file list: %w", game.Name, err)
+	}
+
+	log.Printf("[%s] restoring snapshot %s on local...\n", game.Name, id)
+	mine, err := makeLocalFileList(ctx, game)
+	if err != nil {
+		return err
+	}
+	journal := newSyncJournal(game, snapshot)
+	_, downloads, err := plannedTransfers(game, snapshot, mine, snapshot)
+	if err != nil {
+		return err
+	}
+	if err := staged.handOverStaging(backend, journal, nil, downloads); err != nil {
+		return err
+	}
+	if err := remoteToLocal(ctx, game, backend, snapshot, mine, id, journal); err != nil {
+		return err
+	}
+	return journal.finish()
+}
@@ -0,0 +1,83 @@
+package savesync
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSnapshotID(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, hostname, _ := strings.Cut(snapshotID(now, 1), "_")
+	for seq := 1; seq <= 3; seq++ {
+		id := snapshotID(now, seq)
+		snapshot, ok := parseSnapshotID(id)
+		if !ok {
+			t.Fatalf("parseSnapshotID(%q) failed", id)
+		}
+		if !snapshot.Time.Equal(now) || snapshot.Seq != seq || snapshot.Hostname != hostname {
+			t.Errorf("parseSnapshotID(%q) = %+v", id, snapshot)
+		}
+	}
+	if id := snapshotID(now, 2); !strings.HasPrefix(id, "20260102T030405Z-2_") {
+		t.Errorf("snapshotID = %q, want the number after the time", id)
+	}
+
+	for _, id := range []string{"20260102T030405Z", "20260102T030405Z-1_host", "20260102T030405Z-x_host", "20260102T030405Z-02_host", "20260102_host", "20260102T030405Z_.."} {
+		if _, ok := parseSnapshotID(id); ok {
+			t.Errorf("parseSnapshotID(%q) succeeded", id)
+		}
+	}
+}
+
+func TestNewGenerationIDKeepsHostname(t *testing.T) {
+	game, backend := newTestGame(t)
+	first, err := newGenerationID(game, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, game.RemoteSnapshotMetaPath(first), "{}\n")
+	second, err := newGenerationID(game, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatalf("generation ID %s was reused", first)
+	}
+
+	a, _ := parseSnapshotID(first)
+	b, ok := parseSnapshotID(second)
+	if !ok {
+		t.Fatalf("parseSnapshotID(%q) failed", second)
+	}
+	// 같은 초에 만들었다면 번호로 구분하고 컴퓨터 이름은 그대로 둠
+	if b.Hostname != a.Hostname {
+		t.Errorf("hostname = %q, want %q", b.Hostname, a.Hostname)
+	}
+}
+
+func TestListSnapshotsOrder(t *testing.T) {
+	game, backend := newTestGame(t)
+	now := time.Now().Truncate(time.Second)
+	var want []string
+	for _, id := range []string{snapshotID(now.Add(-time.Second), 1), snapshotID(now, 1), snapshotID(now, 2), snapshotID(now, 10)} {
+		writeTestFile(t, game.RemoteSnapshotMetaPath(id), "{}\n")
+		want = append(want, id)
+	}
+	if err := os.MkdirAll(game.RemoteHistoryDir()+"/not-a-generation", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, err := ListSnapshots(newTestContext(backend), game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, snapshot := range snapshots {
+		got = append(got, snapshot.ID)
+	}
+	if !equalStrings(got, want) {
+		t.Errorf("snapshots = %v, want %v", got, want)
+	}
+}
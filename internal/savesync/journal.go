@@ -0,0 +1,284 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/scp"
+	"scpsave/internal/storage"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 동기화 단계, 단계를 바꾸기 전에 journal.yaml 에 먼저 기록함
+// 중간에 끊기면 다음 동기화 전에 기록된 단계를 보고 되돌리거나 마저 진행함
+const (
+	journalPhaseUpload   = "upload"   // blob 과 새 세대의 remote.yaml 을 올리는 중, 되돌림
+	journalPhaseSwitch   = "switch"   // 현재 세대를 바꾸는 중, 바뀌었는지 보고 정함
+	journalPhaseDownload = "download" // working/ 으로 받는 중, 되돌림
+	journalPhaseApply    = "apply"    // 받은 파일을 로컬에 반영하는 중, 마저 진행함
+	journalPhaseStaged   = "staged"   // 되돌렸고 이어서 전송할 임시 파일만 남음, 다음 동기화에서 쓰지 않으면 지움
+)
+
+// syncJournal 진행 중인 동기화의 기록
+// Files 는 동기화를 마친 뒤 양쪽의 파일 목록이며 끝나면 base.yaml 이 됨
+// Uploads, Downloads 는 전송할 파일로, 끊긴 뒤 남은 임시 파일을 찾을 때 씀
+type syncJournal struct {
+	Phase      string            `yaml:"phase"`
+	Started    time.Time         `yaml:"started"`
+	Generation string            `yaml:"generation,omitempty"`
+	Files      filelist.FileList `yaml:"files,omitempty"`
+	Uploads    []string          `yaml:"uploads,omitempty"`
+	Downloads  []string          `yaml:"downloads,omitempty"`
+	Deletes    []string          `yaml:"deletes,omitempty"`
+
+	game *config.GameConfig
+}
+
+func newSyncJournal(game *config.GameConfig, files filelist.FileList) *syncJournal {
+	return &syncJournal{game: game, Started: time.Now().UTC(), Files: files}
+}
+
+func loadSyncJournal(game *config.GameConfig) (*syncJournal, error) {
+	bt, err := os.ReadFile(game.JournalFilePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("[%s] failed to read sync journal: %w", game.Name, err)
+	}
+	j := &syncJournal{game: game}
+	if err := yaml.Unmarshal(bt, j); err != nil {
+		return nil, fmt.Errorf("[%s] failed to parse sync journal: %w", game.Name, err)
+	}
+	return j, nil
+}
+
+// setPhase 단계를 기록하고 디스크에 내려질 때까지 기다림
+func (j *syncJournal) setPhase(phase string) error {
+	j.Phase = phase
+	bt, err := yaml.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to marshal sync journal: %w", j.game.Name, err)
+	}
+
+	journalPath := j.game.JournalFilePath()
+	tempPath := journalPath + ".temp"
+	f, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to write sync journal: %w", j.game.Name, err)
+	}
+	_, err = f.Write(bt)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, journalPath)
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return fmt.Errorf("[%s] failed to write sync journal: %w", j.game.Name, err)
+	}
+	return nil
+}
+
+// finish base.yaml 을 저장하고 기록을 지움
+func (j *syncJournal) finish() error {
+	baseFilePath := j.game.BaseMetaFilePath()
+	if err := j.Files.Save(baseFilePath); err != nil {
+		return fmt.Errorf("[%s] failed to save metadata for %s: %w", j.game.Name, baseFilePath, err)
+	}
+	if err := os.Remove(j.game.JournalFilePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("[%s] failed to remove sync journal: %w", j.game.Name, err)
+	}
+	return nil
+}
+
+// discard base.yaml 은 그대로 두고 기록만 지움
+func (j *syncJournal) discard() error {
+	if err := os.Remove(j.game.JournalFilePath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("[%s] failed to remove sync journal: %w", j.game.Name, err)
+	}
+	return nil
+}
+
+// recoverSyncJournal 끝나지 않은 동기화가 있으면 되돌리거나 마저 진행함, 원격 잠금을 잡은 상태에서 부름
+//   - upload, switch(세대가 안 바뀐 경우): 새 세대와 current.temp, 새 세대만 참조하던 blob 을 지움
+//   - switch(세대가 바뀐 경우), download: 다 받은 파일만 지움, 원격이 바뀐 것으로 보이므로 다음 동기화에서 다시 받음
+//   - apply: 남은 파일을 옮기고 지운 뒤 base.yaml 을 저장
+//
+// 되돌린 경우 이어서 전송할 수 있도록 .uploading, .download.gz 는 남기고 staged 로 기록해 둠
+// 남은 임시 파일이 있으면 그 기록을 반환하며, 부른 쪽에서 handOverStaging 으로 정리함
+func recoverSyncJournal(ctx context.Context, game *config.GameConfig, backend storage.Backend) (*syncJournal, error) {
+	j, err := loadSyncJournal(game)
+	if err != nil || j == nil {
+		return nil, err
+	}
+	if j.Phase == journalPhaseStaged {
+		return j, nil
+	}
+	log.Printf("[%s] found an interrupted sync in phase %s, recovering...\n", game.Name, j.Phase)
+
+	switch j.Phase {
+	case journalPhaseUpload, journalPhaseSwitch:
+		current, err := readRemoteGeneration(game, backend)
+		if err != nil {
+			return nil, err
+		}
+		if j.Generation != "" && current != j.Generation {
+			if err := backend.DeleteRemoteDir(path.Join(game.RemoteHistoryDir(), j.Generation)); err != nil {
+				return nil, fmt.Errorf("[%s] failed to delete incomplete generation %s: %w", game.Name, j.Generation, err)
+			}
+			_ = backend.DeleteRemoteFile(game.RemoteGenerationUploadPath())
+			if err := deleteUncommittedBlobs(ctx, game, backend, j.Files); err != nil {
+				return nil, fmt.Errorf("[%s] failed to delete blobs of incomplete generation %s: %w", game.Name, j.Generation, err)
+			}
+			log.Printf("[%s] rolled back incomplete generation %s\n", game.Name, j.Generation)
+		}
+		return j, j.stage()
+
+	case journalPhaseDownload:
+		for _, relPath := range j.Downloads {
+			_ = os.Remove(game.LocalFileDownloadPath(relPath))
+		}
+		log.Printf("[%s] rolled back incomplete download\n", game.Name)
+		return j, j.stage()
+
+	case journalPhaseApply:
+		for _, relPath := range j.Downloads {
+			downloadPath := game.LocalFileDownloadPath(relPath)
+			if _, err := os.Stat(downloadPath); err != nil {
+				continue // 이미 옮김
+			}
+			if err := scp.MoveLocalFile(downloadPath, game.LocalFilePath(relPath)); err != nil {
+				return nil, fmt.Errorf("[%s] failed to move local file %s: %w", game.Name, downloadPath, err)
+			}
+		}
+		for _, relPath := range j.Deletes {
+			if err := scp.DeleteLocalFile(game.LocalFilePath(relPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("[%s] failed to delete local file %s: %w", game.Name, relPath, err)
+			}
+		}
+		log.Printf("[%s] finished interrupted local update\n", game.Name)
+		return nil, j.finish()
+	}
+
+	return nil, fmt.Errorf("[%s] unknown sync journal phase %q", game.Name, j.Phase)
+}
+
+// stage 되돌린 동기화에서 남은 임시 파일의 경로만 남김
+func (j *syncJournal) stage() error {
+	j.Generation = ""
+	j.Files = nil
+	j.Deletes = nil
+	return j.setPhase(journalPhaseStaged)
+}
+
+// handOverStaging 되돌린 동기화의 임시 파일 중 이번 동기화에서 이어서 전송하지 않는 것을 지움
+// 이어서 전송할 파일의 기록이 정리되지 않도록 next 는 처음 시작한 시간을 이어받음
+// next 가 nil 이면 동기화할 것이 없으므로 기록도 지움
+func (j *syncJournal) handOverStaging(backend storage.Backend, next *syncJournal, uploads, downloads []string) error {
+	if j == nil {
+		return nil
+	}
+	if next != nil && j.Started.Before(next.Started) {
+		next.Started = j.Started
+	}
+	planned := make(map[string]bool, len(uploads))
+	for _, relPath := range uploads {
+		planned[relPath] = true
+	}
+	for _, relPath := range j.Uploads {
+		if planned[relPath] {
+			continue
+		}
+		uploadPath := j.game.RemoteFileUploadPath(relPath)
+		for _, remotePath := range []string{uploadPath + ".uploading", uploadPath} {
+			if err := backend.DeleteRemoteFile(remotePath); err != nil && !errors.Is(err, storage.ErrNoSuchFile) {
+				log.Printf("[%s] failed to delete stale upload %s: %+v\n", j.game.Name, remotePath, err)
+			}
+		}
+	}
+
+	planned = make(map[string]bool, len(downloads))
+	for _, relPath := range downloads {
+		planned[relPath] = true
+	}
+	for _, relPath := range j.Downloads {
+		if planned[relPath] {
+			continue
+		}
+		downloadPath := j.game.LocalFileDownloadPath(relPath)
+		for _, localPath := range []string{downloadPath + ".download", downloadPath + ".download.gz"} {
+			if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+				log.Printf("[%s] failed to delete stale download %s: %+v\n", j.game.Name, localPath, err)
+			}
+		}
+	}
+
+	if next == nil {
+		return j.discard()
+	}
+	return nil
+}
+
+// plannedTransfers 동기화에서 올리거나 받을 파일
+func plannedTransfers(game *config.GameConfig, merged, mine, remote filelist.FileList) (uploads, downloads []string, err error) {
+	if !merged.Identical(remote) {
+		sources, err := blobSources(game, merged)
+		if err != nil {
+			return nil, nil, err
+		}
+		uploads = sortedValues(sources)
+	}
+	if !merged.Equal(mine) {
+		updated, _ := merged.Diff(mine)
+		downloads = sortedRelPaths(updated)
+	}
+	return uploads, downloads, nil
+}
+
+// pruneTransferProgress 남은 동기화 기록보다 오래된 전송 기록을 지움
+// 이어서 전송할 수 있는 것은 끊긴 동기화에서 남긴 것뿐이므로 그보다 오래된 기록은 쓰이지 않음
+func pruneTransferProgress(games []*config.GameConfig) {
+	cutoff := time.Now()
+	for _, game := range games {
+		if j, err := loadSyncJournal(game); err == nil && j != nil && j.Started.Before(cutoff) {
+			cutoff = j.Started
+		}
+	}
+
+	dir := config.TransferStateDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("failed to list transfer progress: %+v\n", err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove transfer progress %s: %+v\n", entry.Name(), err)
+		}
+	}
+}
+
+// hasSyncJournal 끝나지 않은 동기화 기록이 있는지, 임시 파일만 남은 기록은 제외
+func hasSyncJournal(game *config.GameConfig) bool {
+	j, err := loadSyncJournal(game)
+	return err != nil || (j != nil && j.Phase != journalPhaseStaged)
+}
@@ -0,0 +1,242 @@
+package savesync
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, p, content string) {
+	t.Helper()
+	p = filepath.FromSlash(p)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func saveTestFileList(t *testing.T, p string, files filelist.FileList) {
+	t.Helper()
+	p = filepath.FromSlash(p)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.Save(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertExists(t *testing.T, p string, want bool) {
+	t.Helper()
+	_, err := os.Stat(filepath.FromSlash(p))
+	if exists := err == nil; exists != want {
+		t.Errorf("%s exists = %v, want %v", p, exists, want)
+	}
+}
+
+// testGenerationID days 일 전에 만든 세대의 ID
+func testGenerationID(days int) string {
+	return newSnapshotID(time.Now().AddDate(0, 0, -days))
+}
+
+func TestRecoverSyncJournalRollsBackUpload(t *testing.T) {
+	for _, phase := range []string{journalPhaseUpload, journalPhaseSwitch} {
+		t.Run(phase, func(t *testing.T) {
+			game, backend := newTestGame(t)
+			oldGeneration, newGeneration := testGenerationID(2), testGenerationID(1)
+			oldFiles := filelist.FileList{"b.sav": fileMeta("b", 1)}
+			saveTestFileList(t, game.RemoteSnapshotMetaPath(oldGeneration), oldFiles)
+			if err := switchRemoteGeneration(game, backend, oldGeneration); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, game.RemoteSnapshotMetaPath(newGeneration), "{}\n")
+			writeTestFile(t, game.RemoteBlobPath(fileMeta("a", 1).Hash), "a")
+			writeTestFile(t, game.RemoteBlobPath(fileMeta("b", 1).Hash), "b")
+			writeTestFile(t, game.RemoteGenerationUploadPath(), newGeneration+"\n")
+			writeTestFile(t, game.RemoteFileUploadPath("a.sav")+".uploading", "partial")
+
+			j := newSyncJournal(game, filelist.FileList{"a.sav": fileMeta("a", 1), "b.sav": fileMeta("b", 1)})
+			j.Generation = newGeneration
+			j.Uploads = []string{"a.sav"}
+			if err := j.setPhase(phase); err != nil {
+				t.Fatal(err)
+			}
+
+			staged, err := recoverSyncJournal(newTestContext(backend), game, backend)
+			if err != nil {
+				t.Fatalf("recoverSyncJournal failed: %v", err)
+			}
+			assertExists(t, path.Join(game.RemoteHistoryDir(), newGeneration), false)
+			assertExists(t, game.RemoteGenerationUploadPath(), false)
+			assertExists(t, game.RemoteFileUploadPath("a.sav")+".uploading", true)
+			assertExists(t, game.RemoteBlobPath(fileMeta("a", 1).Hash), false)
+			assertExists(t, game.RemoteBlobPath(fileMeta("b", 1).Hash), true)
+			if current, _ := readRemoteGeneration(game, backend); current != oldGeneration {
+				t.Errorf("current generation = %s, want the old one", current)
+			}
+			if staged == nil || staged.Phase != journalPhaseStaged || len(staged.Uploads) != 1 {
+				t.Fatalf("staged = %+v, want the upload kept", staged)
+			}
+			if hasSyncJournal(game) {
+				t.Errorf("staged journal counts as an interrupted sync")
+			}
+		})
+	}
+}
+
+func TestRecoverSyncJournalKeepsSwitchedGeneration(t *testing.T) {
+	game, backend := newTestGame(t)
+	newGeneration := testGenerationID(1)
+	writeTestFile(t, game.RemoteSnapshotMetaPath(newGeneration), "{}\n")
+	if err := switchRemoteGeneration(game, backend, newGeneration); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, game.BaseMetaFilePath(), "{}\n")
+
+	j := newSyncJournal(game, filelist.FileList{"a.sav": fileMeta("a", 1)})
+	j.Generation = newGeneration
+	if err := j.setPhase(journalPhaseSwitch); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := recoverSyncJournal(newTestContext(backend), game, backend); err != nil {
+		t.Fatalf("recoverSyncJournal failed: %v", err)
+	}
+	assertExists(t, game.RemoteSnapshotMetaPath(newGeneration), true)
+	// base 를 바꾸지 않아야 다음 동기화에서 원격이 바뀐 것으로 보고 받음
+	base, err := filelist.LoadFileList(game.BaseMetaFilePath())
+	if err != nil || len(base) != 0 {
+		t.Errorf("base = %v, %v, want unchanged", base, err)
+	}
+}
+
+func TestRecoverSyncJournalRollsBackDownload(t *testing.T) {
+	game, backend := newTestGame(t)
+	writeTestFile(t, game.LocalFilePath("a.sav"), "old")
+	writeTestFile(t, game.LocalFileDownloadPath("a.sav"), "new")
+	writeTestFile(t, game.LocalFileDownloadPath("b.sav")+".download.gz", "partial")
+
+	j := newSyncJournal(game, filelist.FileList{"a.sav": fileMeta("a", 1), "b.sav": fileMeta("b", 1)})
+	j.Downloads = []string{"a.sav", "b.sav"}
+	if err := j.setPhase(journalPhaseDownload); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := recoverSyncJournal(newTestContext(backend), game, backend)
+	if err != nil {
+		t.Fatalf("recoverSyncJournal failed: %v", err)
+	}
+	assertExists(t, game.LocalFileDownloadPath("a.sav"), false)
+	assertExists(t, game.LocalFileDownloadPath("b.sav")+".download.gz", true)
+	if data, _ := os.ReadFile(game.LocalFilePath("a.sav")); string(data) != "old" {
+		t.Errorf("local file = %q, want it untouched", data)
+	}
+	if staged == nil || len(staged.Downloads) != 2 {
+		t.Fatalf("staged = %+v, want the downloads kept", staged)
+	}
+}
+
+func TestRecoverSyncJournalFinishesApply(t *testing.T) {
+	game, backend := newTestGame(t)
+	writeTestFile(t, game.LocalFilePath("a.sav"), "old")
+	writeTestFile(t, game.LocalFileDownloadPath("a.sav"), "new")
+	writeTestFile(t, game.LocalFilePath("moved.sav"), "already moved")
+	writeTestFile(t, game.LocalFilePath("gone.sav"), "deleted on remote")
+
+	files := filelist.FileList{"a.sav": fileMeta("a", 1), "moved.sav": fileMeta("m", 1)}
+	j := newSyncJournal(game, files)
+	j.Downloads = []string{"a.sav", "moved.sav"}
+	j.Deletes = []string{"gone.sav"}
+	if err := j.setPhase(journalPhaseApply); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := recoverSyncJournal(newTestContext(backend), game, backend)
+	if err != nil {
+		t.Fatalf("recoverSyncJournal failed: %v", err)
+	}
+	if staged != nil {
+		t.Errorf("staged = %+v, want nil", staged)
+	}
+	if data, _ := os.ReadFile(game.LocalFilePath("a.sav")); string(data) != "new" {
+		t.Errorf("a.sav = %q, want the downloaded file", data)
+	}
+	if data, _ := os.ReadFile(game.LocalFilePath("moved.sav")); string(data) != "already moved" {
+		t.Errorf("moved.sav = %q, want it untouched", data)
+	}
+	assertExists(t, game.LocalFilePath("gone.sav"), false)
+	assertExists(t, game.JournalFilePath(), false)
+	base, err := filelist.LoadFileList(game.BaseMetaFilePath())
+	if err != nil || !base.Equal(files) {
+		t.Errorf("base = %v, %v, want the journal files", base, err)
+	}
+}
+
+func TestHandOverStaging(t *testing.T) {
+	game, backend := newTestGame(t)
+	for _, relPath := range []string{"keep.sav", "stale.sav"} {
+		writeTestFile(t, game.RemoteFileUploadPath(relPath)+".uploading", "partial")
+		writeTestFile(t, game.LocalFileDownloadPath(relPath)+".download.gz", "partial")
+	}
+
+	staged := newSyncJournal(game, nil)
+	staged.Started = time.Now().Add(-time.Hour)
+	staged.Uploads = []string{"keep.sav", "stale.sav"}
+	staged.Downloads = []string{"keep.sav", "stale.sav"}
+	if err := staged.stage(); err != nil {
+		t.Fatal(err)
+	}
+
+	next := newSyncJournal(game, nil)
+	if err := staged.handOverStaging(backend, next, []string{"keep.sav"}, []string{"keep.sav"}); err != nil {
+		t.Fatalf("handOverStaging failed: %v", err)
+	}
+	assertExists(t, game.RemoteFileUploadPath("keep.sav")+".uploading", true)
+	assertExists(t, game.RemoteFileUploadPath("stale.sav")+".uploading", false)
+	assertExists(t, game.LocalFileDownloadPath("keep.sav")+".download.gz", true)
+	assertExists(t, game.LocalFileDownloadPath("stale.sav")+".download.gz", false)
+	if !next.Started.Equal(staged.Started) {
+		t.Errorf("next started at %s, want %s", next.Started, staged.Started)
+	}
+
+	// 동기화할 것이 없으면 남은 것을 모두 지우고 기록도 지움
+	if err := staged.handOverStaging(backend, nil, nil, nil); err != nil {
+		t.Fatalf("handOverStaging failed: %v", err)
+	}
+	assertExists(t, game.RemoteFileUploadPath("keep.sav")+".uploading", false)
+	assertExists(t, game.LocalFileDownloadPath("keep.sav")+".download.gz", false)
+	assertExists(t, game.JournalFilePath(), false)
+}
+
+func TestPruneTransferProgress(t *testing.T) {
+	game, _ := newTestGame(t)
+	dir := config.TransferStateDir()
+	writeTestFile(t, filepath.Join(dir, "old.yaml"), "offset: 1\n")
+	writeTestFile(t, filepath.Join(dir, "resumable.yaml"), "offset: 1\n")
+	oldTime := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old.yaml"), oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+
+	staged := newSyncJournal(game, nil)
+	staged.Started = time.Now().Add(-time.Hour)
+	if err := staged.stage(); err != nil {
+		t.Fatal(err)
+	}
+	pruneTransferProgress([]*config.GameConfig{game})
+	assertExists(t, filepath.Join(dir, "old.yaml"), false)
+	assertExists(t, filepath.Join(dir, "resumable.yaml"), true)
+
+	// 남은 동기화가 없으면 이어서 전송할 것도 없음
+	if err := staged.discard(); err != nil {
+		t.Fatal(err)
+	}
+	pruneTransferProgress([]*config.GameConfig{game})
+	assertExists(t, filepath.Join(dir, "resumable.yaml"), false)
+}
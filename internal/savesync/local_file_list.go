@@ -0,0 +1,44 @@
+package savesync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+)
+
+type contextRehashKey struct{}
+
+// NewContextWithRehash 해시 캐시를 쓰지 않고 모든 로컬 파일의 해시를 다시 계산
+func NewContextWithRehash(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextRehashKey{}, true)
+}
+
+func isRehash(ctx context.Context) bool {
+	rehash, _ := ctx.Value(contextRehashKey{}).(bool)
+	return rehash
+}
+
+// makeLocalFileList 게임의 해시 캐시를 사용해서 로컬 파일 목록을 만듦
+// dry run 에서는 캐시를 읽기만 하고 저장하지 않음
+func makeLocalFileList(ctx context.Context, game *config.GameConfig) (filelist.FileList, error) {
+	cache := filelist.LoadHashCache(game.HashCacheFilePath())
+	if isRehash(ctx) {
+		cache.Reset()
+	}
+
+	mine, err := filelist.MakeFileList(game.LocalDir, game.FileMatcher, cache)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to make local file list: %w", game.Name, err)
+	}
+
+	if isDryRun(ctx) {
+		return mine, nil
+	}
+	// 캐시는 다음 검사를 빠르게 할 뿐이므로 실패는 기록만 함
+	if err := cache.Save(); err != nil {
+		log.Printf("[%s] failed to save hash cache: %+v\n", game.Name, err)
+	}
+	return mine, nil
+}
@@ -2,62 +2,87 @@ package savesync
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path"
 	"scpsave/internal/config"
 	"scpsave/internal/filelist"
-	"scpsave/internal/scp"
+	"scpsave/internal/storage"
+	"time"
 )
 
+// localToRemote mine 을 새 세대로 올리고 현재 세대를 바꿈, 새 세대 ID 를 반환
+// 원격에 없는 내용만 blob 으로 올린 뒤 remote.yaml 을 올리고 현재 세대를 바꿈
+// base.yaml 은 부른 쪽에서 journal 을 마칠 때 저장
 func localToRemote(
 	ctx context.Context,
 	game *config.GameConfig,
-	scpclient *scp.Client,
+	backend storage.Backend,
 	mine filelist.FileList,
 	remote filelist.FileList,
-) error {
-	updated, removed := mine.Diff(remote)
+	generation string,
+	journal *syncJournal,
+) (string, error) {
+	newGeneration, err := newGenerationID(game, backend)
+	if err != nil {
+		return "", err
+	}
+	sources, err := blobSources(game, mine)
+	if err != nil {
+		return "", err
+	}
+	journal.Generation = newGeneration
+	journal.Uploads = sortedValues(sources)
+	if err := journal.setPhase(journalPhaseUpload); err != nil {
+		return "", err
+	}
 
 	log.Printf("[%s] start uploading...\n", game.Name)
-
-	uploaded := make([]string, 0, len(updated)*2)
-	for relPath := range updated {
-		remoteUpload := game.RemoteFileUploadPath(relPath)
-		log.Printf("[%s] uploading file %s...", game.Name, relPath)
-		if err := scpclient.UploadFile(ctx, game.LocalFilePath(relPath), remoteUpload); err != nil {
-			return fmt.Errorf("[%s] failed to upload file %s: %w", game.Name, relPath, err)
-		}
-		uploaded = append(uploaded, remoteUpload, game.RemoteFilePath(relPath))
+	if err := uploadBlobs(ctx, game, backend, sources, remote, generation); err != nil {
+		return "", err
 	}
 
+	// remote.yaml 이 있는 세대만 완성된 것으로 봄
 	log.Printf("[%s] uploading metadata\n", game.Name)
 	remoteMetaLocal := game.RemoteMetaFileLocalPath()
 	if err := mine.Save(remoteMetaLocal); err != nil {
-		return fmt.Errorf("[%s] failed to save metadata for %s: %w", game.Name, remoteMetaLocal, err)
+		return "", fmt.Errorf("[%s] failed to save metadata for %s: %w", game.Name, remoteMetaLocal, err)
 	}
-	remoteUpload := game.RemoteMetaFileUploadPath()
-	if err := scpclient.UploadFile(ctx, remoteMetaLocal, remoteUpload); err != nil {
-		return fmt.Errorf("[%s] failed to upload metadata for %s: %w", game.Name, remoteMetaLocal, err)
+	if err := backend.UploadFile(ctx, remoteMetaLocal, game.RemoteSnapshotMetaPath(newGeneration), ""); err != nil {
+		return "", fmt.Errorf("[%s] failed to upload metadata for %s: %w", game.Name, remoteMetaLocal, err)
 	}
-	uploaded = append(uploaded, remoteUpload, game.RemoteMetaFileRemotePath())
 
-	for i := 0; i < len(uploaded); i += 2 {
-		if err := scpclient.MoveRemoteFile(uploaded[i], uploaded[i+1]); err != nil {
-			return fmt.Errorf("[%s] failed to move remote file %s: %w", game.Name, uploaded[i], err)
-		}
+	if err := checkRemoteLock(ctx); err != nil {
+		return "", err
 	}
-
-	for relPath := range removed {
-		log.Printf("[%s] deleting remote file %s\n", game.Name, relPath)
-		if err := scpclient.DeleteRemoteFile(game.RemoteFilePath(relPath)); err != nil {
-			return fmt.Errorf("[%s] failed to delete remote file %s: %w", game.Name, relPath, err)
-		}
+	if err := journal.setPhase(journalPhaseSwitch); err != nil {
+		return "", err
+	}
+	if err := switchRemoteGeneration(game, backend, newGeneration); err != nil {
+		return "", err
 	}
+	log.Printf("[%s] switched remote to generation %s\n", game.Name, newGeneration)
 
-	baseFilePath := game.BaseMetaFilePath()
-	if err := mine.Save(baseFilePath); err != nil {
-		return fmt.Errorf("[%s] failed to save metadata for %s: %w", game.Name, baseFilePath, err)
+	// 동기화는 이미 끝났으므로 정리 실패는 기록만 함
+	if err := collectGarbage(ctx, game, backend, newGeneration); err != nil {
+		log.Printf("[%s] failed to clean up old generations: %+v\n", game.Name, err)
 	}
 
-	return nil
+	return newGeneration, nil
+}
+
+// newGenerationID 같은 초에 올린 세대가 있으면 시간 뒤에 번호를 붙임
+func newGenerationID(game *config.GameConfig, backend storage.Backend) (string, error) {
+	now := time.Now()
+	for seq := 1; ; seq++ {
+		id := snapshotID(now, seq)
+		_, err := backend.StatRemoteFile(path.Join(game.RemoteHistoryDir(), id))
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return id, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("[%s] failed to check generation %s: %w", game.Name, id, err)
+		}
+	}
 }
@@ -0,0 +1,268 @@
+package savesync
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"scpsave/internal/config"
+	"scpsave/internal/storage"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrRemoteLocked   = errors.New("game is being synced by another machine")
+	ErrRemoteLockLost = errors.New("remote lock was lost")
+)
+
+// lockInfo 원격 잠금 파일의 내용
+// Token 은 잠금을 만든 프로세스를 구별하기 위한 임의의 값
+type lockInfo struct {
+	Token    string    `yaml:"token"`
+	Owner    string    `yaml:"owner"`
+	Hostname string    `yaml:"hostname"`
+	PID      int       `yaml:"pid"`
+	Acquired time.Time `yaml:"acquired"`
+	Expires  time.Time `yaml:"expires"`
+}
+
+func (l *lockInfo) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) since %s, expires %s",
+		l.Owner, l.Hostname, l.PID,
+		l.Acquired.Local().Format("2006-01-02 15:04:05"),
+		l.Expires.Local().Format("2006-01-02 15:04:05"))
+}
+
+// remoteLock 게임 하나를 동기화하는 동안 잡고 있는 원격 잠금
+// 잡고 있는 동안 만료 시간을 주기적으로 늘리고, 다른 쪽에 뺏기거나 늘리지 못한 채 만료되면 ctx 를 취소함
+type remoteLock struct {
+	game    *config.GameConfig
+	backend storage.Backend
+	timeout time.Duration
+	info    lockInfo
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// acquireRemoteLock 잠금을 잡음, 잠금을 잡은 동안의 작업은 lock.ctx 로 함
+func acquireRemoteLock(ctx context.Context, game *config.GameConfig, backend storage.Backend) (*remoteLock, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("[%s] failed to create lock token: %w", game.Name, err)
+	}
+	hostname, _ := os.Hostname()
+	owner := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		owner = u.Username
+	}
+
+	timeout := time.Duration(config.Value.LockTimeout) * time.Second
+	now := time.Now().UTC()
+	l := &remoteLock{
+		game:    game,
+		backend: backend,
+		timeout: timeout,
+		info: lockInfo{
+			Token:    hex.EncodeToString(token),
+			Owner:    owner,
+			Hostname: hostname,
+			PID:      os.Getpid(),
+			Acquired: now,
+			Expires:  now.Add(timeout),
+		},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	l.ctx, l.cancel = context.WithCancelCause(ctx)
+
+	lockPath := game.RemoteLockPath()
+	for range 3 {
+		data, err := yaml.Marshal(&l.info)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] failed to marshal remote lock: %w", game.Name, err)
+		}
+		err = backend.CreateRemoteFile(lockPath, data)
+		if err == nil {
+			go l.refresh()
+			return l, nil
+		}
+		if !errors.Is(err, storage.ErrFileExists) {
+			return nil, fmt.Errorf("[%s] failed to create remote lock: %w", game.Name, err)
+		}
+
+		holderData, holder, err := l.readHolder()
+		if err != nil {
+			if errors.Is(err, storage.ErrNoSuchFile) {
+				continue // 그 사이에 풀림
+			}
+			return nil, err
+		}
+		if holder.Token == l.info.Token {
+			// 다시 연결하면서 재시도한 요청이 이미 잠금을 만든 경우
+			go l.refresh()
+			return l, nil
+		}
+		if time.Now().Before(holder.Expires) {
+			return nil, fmt.Errorf("[%s] %w: locked by %s", game.Name, ErrRemoteLocked, holder)
+		}
+
+		log.Printf("[%s] taking over stale remote lock of %s\n", game.Name, holder)
+		if err := l.removeStale(holderData); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("[%s] %w: failed to take over the remote lock", game.Name, ErrRemoteLocked)
+}
+
+// checkRemoteLock 잠금을 잃었거나 취소되었는지, 원격에 반영하는 단계 전마다 확인
+func checkRemoteLock(ctx context.Context) error {
+	return context.Cause(ctx)
+}
+
+// readHolder 지금 잠금을 가진 쪽의 정보를 읽음
+// 만드는 중이거나 깨진 잠금 파일은 수정 시간으로 만료 시간을 정함
+func (l *remoteLock) readHolder() ([]byte, *lockInfo, error) {
+	lockPath := l.game.RemoteLockPath()
+	data, err := l.backend.ReadRemoteFile(lockPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("[%s] failed to read remote lock: %w", l.game.Name, err)
+	}
+
+	var holder lockInfo
+	if err := yaml.Unmarshal(data, &holder); err != nil || holder.Token == "" {
+		info, err := l.backend.StatRemoteFile(lockPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		holder = lockInfo{Owner: "unknown", Hostname: "unknown", Acquired: info.ModTime, Expires: info.ModTime.Add(l.timeout)}
+	}
+	return data, &holder, nil
+}
+
+// removeStale 만료된 잠금을 치움
+// 이름을 바꿔서 가져온 뒤 내용이 다르면 그 사이 다른 쪽이 새로 잡은 것이므로 되돌려 놓음
+func (l *remoteLock) removeStale(staleData []byte) error {
+	lockPath := l.game.RemoteLockPath()
+	stalePath := lockPath + ".stale." + l.info.Token
+	if err := l.backend.MoveRemoteFile(lockPath, stalePath); err != nil {
+		if _, statErr := l.backend.StatRemoteFile(lockPath); errors.Is(statErr, storage.ErrNoSuchFile) {
+			return nil // 다른 쪽이 먼저 치움
+		}
+		return fmt.Errorf("[%s] failed to take over remote lock: %w", l.game.Name, err)
+	}
+
+	data, err := l.backend.ReadRemoteFile(stalePath)
+	if err != nil {
+		return fmt.Errorf("[%s] failed to read stale remote lock: %w", l.game.Name, err)
+	}
+	if !bytes.Equal(data, staleData) {
+		if err := l.backend.MoveRemoteFile(stalePath, lockPath); err != nil {
+			return fmt.Errorf("[%s] failed to put back remote lock: %w", l.game.Name, err)
+		}
+		return fmt.Errorf("[%s] %w: the remote lock was taken by another machine", l.game.Name, ErrRemoteLocked)
+	}
+	if err := l.backend.DeleteRemoteFile(stalePath); err != nil {
+		log.Printf("[%s] failed to delete stale remote lock: %+v\n", l.game.Name, err)
+	}
+	return nil
+}
+
+func (l *remoteLock) refresh() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.timeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			err := l.extend()
+			if err == nil {
+				continue
+			}
+			if errors.Is(err, ErrRemoteLockLost) {
+				l.lose(err)
+				return
+			}
+			log.Printf("[%s] failed to refresh remote lock: %+v\n", l.game.Name, err)
+			if time.Now().After(l.info.Expires) {
+				l.lose(fmt.Errorf("%w: it expired at %s before it could be refreshed", ErrRemoteLockLost, l.info.Expires.Local().Format("2006-01-02 15:04:05")))
+				return
+			}
+		}
+	}
+}
+
+// lose 잠금 없이 진행하지 않도록 진행 중인 작업을 취소
+func (l *remoteLock) lose(err error) {
+	err = fmt.Errorf("[%s] %w", l.game.Name, err)
+	log.Printf("%+v, aborting sync\n", err)
+	l.cancel(err)
+}
+
+// extend 새 만료 시간으로 쓴 파일을 잠금 파일 위로 옮김
+func (l *remoteLock) extend() error {
+	_, holder, err := l.readHolder()
+	if err != nil {
+		if errors.Is(err, storage.ErrNoSuchFile) {
+			return fmt.Errorf("%w: the lock file was removed", ErrRemoteLockLost)
+		}
+		return err
+	}
+	if holder.Token != l.info.Token {
+		return fmt.Errorf("%w: taken over by %s", ErrRemoteLockLost, holder)
+	}
+
+	info := l.info
+	info.Expires = time.Now().UTC().Add(l.timeout)
+	data, err := yaml.Marshal(&info)
+	if err != nil {
+		return err
+	}
+
+	lockPath := l.game.RemoteLockPath()
+	refreshPath := lockPath + ".refresh." + l.info.Token
+	_ = l.backend.DeleteRemoteFile(refreshPath)
+	if err := l.backend.CreateRemoteFile(refreshPath, data); err != nil {
+		return err
+	}
+	if err := l.backend.MoveRemoteFile(refreshPath, lockPath); err != nil {
+		return err
+	}
+	l.info = info
+	return nil
+}
+
+// release 잠금이 아직 자기 것이면 지움
+func (l *remoteLock) release() {
+	close(l.stop)
+	<-l.done
+	defer l.cancel(nil)
+
+	_, holder, err := l.readHolder()
+	if err != nil {
+		log.Printf("[%s] failed to read remote lock for release: %+v\n", l.game.Name, err)
+		return
+	}
+	if holder.Token != l.info.Token {
+		log.Printf("[%s] remote lock was taken over by %s\n", l.game.Name, holder)
+		return
+	}
+	if err := l.backend.DeleteRemoteFile(l.game.RemoteLockPath()); err != nil {
+		log.Printf("[%s] failed to release remote lock: %+v\n", l.game.Name, err)
+	}
+}
@@ -0,0 +1,219 @@
+package savesync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"scpsave/internal/localdir"
+	"scpsave/internal/storage"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// newTestGame 임시 디렉터리를 원격 저장소로 쓰는 게임, 작업 디렉터리도 임시 디렉터리로 바꿈
+func newTestGame(t *testing.T) (*config.GameConfig, *localdir.Backend) {
+	t.Helper()
+
+	stateDir := config.StateDir
+	value := config.Value
+	t.Cleanup(func() {
+		config.StateDir = stateDir
+		config.Value = value
+	})
+	config.StateDir = t.TempDir()
+	config.Value = &config.Config{LockTimeout: 1, HistoryCount: 2}
+
+	matcher, err := filelist.NewMatcher(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := &config.GameConfig{
+		Name:              "test",
+		AltName:           "test",
+		LocalDir:          t.TempDir(),
+		RemoteRoot:        filepath.ToSlash(t.TempDir()),
+		ParallelTransfers: 2,
+		FileMatcher:       matcher,
+	}
+	if err := os.MkdirAll(filepath.Join(config.WorkingDir(), game.AltName), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return game, localdir.NewBackend()
+}
+
+// newTestContext SyncGame 이 backend 를 쓰게 하는 context
+func newTestContext(backend storage.Backend) context.Context {
+	backends := storage.NewBackends(func(string) (storage.Backend, error) { return backend, nil })
+	return storage.NewContextWithBackends(context.Background(), backends)
+}
+
+func TestRemoteLockExclusive(t *testing.T) {
+	game, backend := newTestGame(t)
+	config.Value.LockTimeout = 60
+
+	lock, err := acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock failed: %v", err)
+	}
+	if _, err := acquireRemoteLock(context.Background(), game, backend); !errors.Is(err, ErrRemoteLocked) {
+		t.Fatalf("second acquireRemoteLock = %v, want ErrRemoteLocked", err)
+	}
+
+	lock.release()
+	if _, err := os.Stat(game.RemoteLockPath()); !os.IsNotExist(err) {
+		t.Fatalf("lock file still exists after release: %v", err)
+	}
+	if err := checkRemoteLock(lock.ctx); err == nil {
+		t.Errorf("lock context is not cancelled after release")
+	}
+
+	lock, err = acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock after release failed: %v", err)
+	}
+	lock.release()
+}
+
+func TestRemoteLockTakesOverExpiredLock(t *testing.T) {
+	game, backend := newTestGame(t)
+	config.Value.LockTimeout = 60
+
+	stale := lockInfo{
+		Token:    "stale",
+		Owner:    "someone",
+		Hostname: "elsewhere",
+		Acquired: time.Now().Add(-2 * time.Hour),
+		Expires:  time.Now().Add(-time.Hour),
+	}
+	data, err := yaml.Marshal(&stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.CreateRemoteFile(game.RemoteLockPath(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock did not take over an expired lock: %v", err)
+	}
+	defer lock.release()
+
+	_, holder, err := lock.readHolder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder.Token != lock.info.Token {
+		t.Errorf("lock is held by %s after takeover", holder)
+	}
+}
+
+func TestRemoteLockKeepsLiveLock(t *testing.T) {
+	game, backend := newTestGame(t)
+
+	live := lockInfo{Token: "live", Owner: "someone", Hostname: "elsewhere", Acquired: time.Now(), Expires: time.Now().Add(time.Hour)}
+	data, err := yaml.Marshal(&live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.CreateRemoteFile(game.RemoteLockPath(), data); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := acquireRemoteLock(context.Background(), game, backend); !errors.Is(err, ErrRemoteLocked) {
+		t.Fatalf("acquireRemoteLock = %v, want ErrRemoteLocked", err)
+	}
+	got, err := backend.ReadRemoteFile(game.RemoteLockPath())
+	if err != nil || string(got) != string(data) {
+		t.Errorf("live lock was changed: %q, %v", got, err)
+	}
+}
+
+func TestRemoteLockRefreshExtendsExpiry(t *testing.T) {
+	game, backend := newTestGame(t)
+
+	lock, err := acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock failed: %v", err)
+	}
+	defer lock.release()
+	_, first, err := lock.readHolder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lock_timeout 1초, 갱신 주기는 1/3초
+	time.Sleep(1500 * time.Millisecond)
+	if err := checkRemoteLock(lock.ctx); err != nil {
+		t.Fatalf("lock was lost while refreshing: %v", err)
+	}
+	_, holder, err := lock.readHolder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !holder.Expires.After(first.Expires) {
+		t.Errorf("expiry was not extended: %s, first %s", holder.Expires, first.Expires)
+	}
+}
+
+func TestRemoteLockLostOnTakeover(t *testing.T) {
+	game, backend := newTestGame(t)
+
+	lock, err := acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock failed: %v", err)
+	}
+
+	// 다른 컴퓨터가 만료된 것으로 보고 가져간 상황
+	other := lockInfo{Token: "other", Owner: "someone", Hostname: "elsewhere", Acquired: time.Now(), Expires: time.Now().Add(time.Hour)}
+	data, err := yaml.Marshal(&other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.FromSlash(game.RemoteLockPath()), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lock.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock context was not cancelled after takeover")
+	}
+	if err := checkRemoteLock(lock.ctx); !errors.Is(err, ErrRemoteLockLost) {
+		t.Errorf("checkRemoteLock = %v, want ErrRemoteLockLost", err)
+	}
+
+	// 가져간 쪽의 잠금은 풀지 않음
+	lock.release()
+	got, err := backend.ReadRemoteFile(game.RemoteLockPath())
+	if err != nil || string(got) != string(data) {
+		t.Errorf("lock of the other machine was changed: %q, %v", got, err)
+	}
+}
+
+func TestRemoteLockLostOnRemoval(t *testing.T) {
+	game, backend := newTestGame(t)
+
+	lock, err := acquireRemoteLock(context.Background(), game, backend)
+	if err != nil {
+		t.Fatalf("acquireRemoteLock failed: %v", err)
+	}
+	defer lock.release()
+
+	if err := backend.DeleteRemoteFile(game.RemoteLockPath()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lock.ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock context was not cancelled after the lock file was removed")
+	}
+	if err := checkRemoteLock(lock.ctx); !errors.Is(err, ErrRemoteLockLost) {
+		t.Errorf("checkRemoteLock = %v, want ErrRemoteLockLost", err)
+	}
+}
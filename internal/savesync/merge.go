@@ -0,0 +1,75 @@
+package savesync
+
+import (
+	"fmt"
+	"log"
+	"scpsave/internal/config"
+	"scpsave/internal/conio"
+	"scpsave/internal/filelist"
+	"sort"
+)
+
+// mergeFileLists base 를 기준으로 파일마다 mine, remote 를 비교해서 동기화 후의 파일 목록을 만듦
+// 한쪽에서만 바뀐 파일은 바뀐 쪽을 따르고, 양쪽에서 다르게 바뀐 파일은 conflicts 로 돌려줌
+// 내용이 같으면 수정 시간만 다르더라도 바뀌지 않은 것으로 보고 로컬의 메타데이터를 사용
+// 로컬은 그대로이고 원격에서 수정 시간만 바뀐 경우는 원격의 메타데이터를 따름
+func mergeFileLists(base, mine, remote filelist.FileList) (merged filelist.FileList, conflicts []string) {
+	relPaths := make(map[string]struct{}, len(mine))
+	for _, fl := range []filelist.FileList{base, mine, remote} {
+		for relPath := range fl {
+			relPaths[relPath] = struct{}{}
+		}
+	}
+
+	merged = make(filelist.FileList, len(relPaths))
+	for relPath := range relPaths {
+		mineChanged := !base[relPath].SameContent(mine[relPath])
+		remoteChanged := !base[relPath].SameContent(remote[relPath])
+
+		var metadata *filelist.FileMetadata
+		switch {
+		case !remoteChanged && identicalMetadata(base[relPath], mine[relPath]) && !identicalMetadata(base[relPath], remote[relPath]):
+			metadata = remote[relPath]
+		case !remoteChanged, mine[relPath].SameContent(remote[relPath]):
+			metadata = mine[relPath]
+		case !mineChanged:
+			metadata = remote[relPath]
+		default:
+			conflicts = append(conflicts, relPath)
+			continue
+		}
+		if metadata != nil {
+			merged[relPath] = metadata
+		}
+	}
+
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+func identicalMetadata(a, b *filelist.FileMetadata) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// resolveConflicts 충돌한 파일마다 사용자에게 물어서 merged 에 반영
+func resolveConflicts(game *config.GameConfig, merged, mine, remote filelist.FileList, conflicts []string) error {
+	for _, relPath := range conflicts {
+		var metadata *filelist.FileMetadata
+		switch conio.ResolveConflict(game, relPath, mine[relPath], remote[relPath]) {
+		case conio.LocalToRemote:
+			metadata = mine[relPath]
+		case conio.RemoteToLocal:
+			metadata = remote[relPath]
+		default:
+			return fmt.Errorf("[%s] conflict resolution aborted by user", game.Name)
+		}
+		log.Printf("[%s] resolved conflict of %s\n", game.Name, relPath)
+		if metadata != nil {
+			merged[relPath] = metadata
+		}
+	}
+	return nil
+}
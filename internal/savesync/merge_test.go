@@ -0,0 +1,186 @@
+package savesync
+
+import (
+	"os"
+	"path/filepath"
+	"scpsave/internal/config"
+	"scpsave/internal/filelist"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fileMeta content 로 해시와 크기를 정하고 mtime 은 그대로 씀
+func fileMeta(content string, mtime int64) *filelist.FileMetadata {
+	return &filelist.FileMetadata{
+		ModifiedTime: mtime,
+		Size:         int64(len(content)),
+		Hash:         strings.Repeat(content, 128/len(content)),
+	}
+}
+
+func TestMergeFileLists(t *testing.T) {
+	const (
+		keepMine   = "mine"
+		keepRemote = "remote"
+		deleted    = "deleted"
+		conflict   = "conflict"
+	)
+
+	tests := []struct {
+		name               string
+		base, mine, remote *filelist.FileMetadata
+		want               string
+	}{
+		{"unchanged", fileMeta("a", 1), fileMeta("a", 1), fileMeta("a", 1), keepMine},
+		{"modified locally", fileMeta("a", 1), fileMeta("b", 2), fileMeta("a", 1), keepMine},
+		{"modified remotely", fileMeta("a", 1), fileMeta("a", 1), fileMeta("b", 2), keepRemote},
+		{"same change on both sides", fileMeta("a", 1), fileMeta("b", 2), fileMeta("b", 3), keepMine},
+		{"different changes on both sides", fileMeta("a", 1), fileMeta("b", 2), fileMeta("c", 3), conflict},
+
+		{"mtime only locally", fileMeta("a", 1), fileMeta("a", 2), fileMeta("a", 1), keepMine},
+		{"mtime only remotely", fileMeta("a", 1), fileMeta("a", 1), fileMeta("a", 2), keepRemote},
+		{"mtime only on both sides", fileMeta("a", 1), fileMeta("a", 2), fileMeta("a", 3), keepMine},
+		{"mtime only locally, modified remotely", fileMeta("a", 1), fileMeta("a", 2), fileMeta("b", 3), keepRemote},
+		{"modified locally, mtime only remotely", fileMeta("a", 1), fileMeta("b", 2), fileMeta("a", 3), keepMine},
+
+		{"deleted locally", fileMeta("a", 1), nil, fileMeta("a", 1), deleted},
+		{"deleted remotely", fileMeta("a", 1), fileMeta("a", 1), nil, deleted},
+		{"deleted on both sides", fileMeta("a", 1), nil, nil, deleted},
+		{"deleted remotely, mtime only locally", fileMeta("a", 1), fileMeta("a", 2), nil, deleted},
+		{"deleted locally, modified remotely", fileMeta("a", 1), nil, fileMeta("b", 2), conflict},
+		{"modified locally, deleted remotely", fileMeta("a", 1), fileMeta("b", 2), nil, conflict},
+
+		{"added locally", nil, fileMeta("a", 1), nil, keepMine},
+		{"added remotely", nil, nil, fileMeta("a", 1), keepRemote},
+		{"same file added on both sides", nil, fileMeta("a", 1), fileMeta("a", 2), keepMine},
+		{"different files added on both sides", nil, fileMeta("a", 1), fileMeta("b", 2), conflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lists := make([]filelist.FileList, 3)
+			for i, metadata := range []*filelist.FileMetadata{tt.base, tt.mine, tt.remote} {
+				lists[i] = filelist.FileList{"other.sav": fileMeta("z", 1)}
+				if metadata != nil {
+					lists[i]["save.sav"] = metadata
+				}
+			}
+
+			merged, conflicts := mergeFileLists(lists[0], lists[1], lists[2])
+
+			if merged["other.sav"] != lists[1]["other.sav"] {
+				t.Errorf("unrelated file was not kept")
+			}
+			if tt.want == conflict {
+				if len(conflicts) != 1 || conflicts[0] != "save.sav" {
+					t.Fatalf("conflicts = %v, want [save.sav]", conflicts)
+				}
+				if _, exists := merged["save.sav"]; exists {
+					t.Errorf("conflicting file is in merged list")
+				}
+				return
+			}
+			if len(conflicts) != 0 {
+				t.Fatalf("conflicts = %v, want none", conflicts)
+			}
+
+			got, exists := merged["save.sav"]
+			switch tt.want {
+			case deleted:
+				if exists {
+					t.Errorf("merged has %+v, want deleted", got)
+				}
+			case keepMine:
+				if got != tt.mine {
+					t.Errorf("merged has %+v, want local %+v", got, tt.mine)
+				}
+			case keepRemote:
+				if got != tt.remote {
+					t.Errorf("merged has %+v, want remote %+v", got, tt.remote)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeFileListsSortsConflicts(t *testing.T) {
+	base := filelist.FileList{}
+	mine := filelist.FileList{}
+	remote := filelist.FileList{}
+	for _, relPath := range []string{"c.sav", "a.sav", "b/d.sav"} {
+		base[relPath] = fileMeta("a", 1)
+		mine[relPath] = fileMeta("b", 2)
+		remote[relPath] = fileMeta("c", 3)
+	}
+
+	_, conflicts := mergeFileLists(base, mine, remote)
+	if strings.Join(conflicts, ",") != "a.sav,b/d.sav,c.sav" {
+		t.Errorf("conflicts = %v, want sorted", conflicts)
+	}
+}
+
+func TestSyncGameAppliesModifiedTimeOnly(t *testing.T) {
+	game, backend := newTestGame(t)
+	ctx := newTestContext(backend)
+	syncTestFile(t, ctx, game, "a.sav", "content")
+
+	other := *game
+	other.LocalDir = t.TempDir()
+	stateDir := config.StateDir
+	otherStateDir := t.TempDir()
+	switchMachine := func(dir string) {
+		config.StateDir = dir
+		if err := os.MkdirAll(filepath.Join(config.WorkingDir(), game.AltName), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	switchMachine(otherStateDir)
+	if err := SyncGame(ctx, &other, false); err != nil {
+		t.Fatalf("SyncGame on the other machine failed: %v", err)
+	}
+
+	// 내용은 그대로 두고 수정 시간만 바꿈
+	switchMachine(stateDir)
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(game.LocalFilePath("a.sav"), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := readRemoteGeneration(game, backend)
+	if err := SyncGame(ctx, game, false); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := readRemoteGeneration(game, backend)
+	if after == before {
+		t.Fatalf("modification time was not published as a new generation")
+	}
+
+	switchMachine(otherStateDir)
+	if err := SyncGame(ctx, &other, false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(other.LocalFilePath("a.sav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("modification time = %s, want %s", info.ModTime(), modTime)
+	}
+	base, err := filelist.LoadFileList(other.BaseMetaFilePath())
+	if err != nil || base["a.sav"].ModifiedTime != modTime.UnixNano() {
+		t.Errorf("base = %v, %v, want the remote modification time", base, err)
+	}
+
+	// 반영한 뒤에는 어느 쪽에서도 더 할 것이 없음
+	for _, g := range []*config.GameConfig{&other, game} {
+		if g == game {
+			switchMachine(stateDir)
+		}
+		if err := SyncGame(ctx, g, false); err != nil {
+			t.Fatal(err)
+		}
+		if current, _ := readRemoteGeneration(game, backend); current != after {
+			t.Errorf("generation changed to %s after the modification time was applied", current)
+		}
+	}
+}
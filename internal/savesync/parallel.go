@@ -0,0 +1,44 @@
+package savesync
+
+import (
+	"errors"
+	"scpsave/internal/sem"
+	"sync"
+	"sync/atomic"
+)
+
+// runParallel relPaths 마다 fn 을 최대 n 개까지 동시에 실행
+// 실패가 생기면 새 작업은 시작하지 않고 진행 중인 작업이 끝나기를 기다려서 에러를 모아 반환
+func runParallel(n int, relPaths []string, fn func(relPath string) error) error {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		failed atomic.Bool
+	)
+
+	sem := sem.NewSemaphore(n)
+	for _, relPath := range relPaths {
+		sem.Acquire()
+		if failed.Load() {
+			sem.Release()
+			break
+		}
+
+		wg.Add(1)
+		go func(relPath string) {
+			defer wg.Done()
+			defer sem.Release()
+
+			if err := fn(relPath); err != nil {
+				failed.Store(true)
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(relPath)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
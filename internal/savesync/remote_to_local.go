@@ -4,30 +4,67 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
 	"scpsave/internal/config"
 	"scpsave/internal/filelist"
 	"scpsave/internal/scp"
+	"scpsave/internal/storage"
+	"time"
 )
 
+// remoteToLocal remote 와 다른 로컬 파일을 working/ 으로 받은 뒤 한꺼번에 반영
+// base.yaml 은 부른 쪽에서 journal 을 마칠 때 저장
 func remoteToLocal(
 	ctx context.Context,
 	game *config.GameConfig,
-	scpclient *scp.Client,
+	backend storage.Backend,
 	remote filelist.FileList,
 	mine filelist.FileList,
+	generation string,
+	journal *syncJournal,
 ) error {
 	updated, removed := remote.Diff(mine)
 
 	log.Printf("[%s] start downloading...\n", game.Name)
 
+	relPaths := make([]string, 0, len(updated))
 	downloaded := make([]string, 0, len(updated)*2)
-	for relPath, metadata := range updated {
-		localDownload := game.LocalFileDownloadPath(relPath)
+	for relPath := range updated {
+		relPaths = append(relPaths, relPath)
+		downloaded = append(downloaded, game.LocalFileDownloadPath(relPath), game.LocalFilePath(relPath))
+	}
+	journal.Downloads = sortedRelPaths(updated)
+	journal.Deletes = sortedRelPaths(removed)
+	if err := journal.setPhase(journalPhaseDownload); err != nil {
+		return err
+	}
+
+	err := runParallel(game.ParallelTransfers, relPaths, func(relPath string) error {
+		metadata := updated[relPath]
 		log.Printf("[%s] downloading file %s\n", game.Name, relPath)
-		if err := scpclient.DownloadFile(ctx, game.RemoteFilePath(relPath), localDownload, metadata.ModifiedTime); err != nil {
+		if err := downloadBlob(ctx, game, backend, generation, relPath, metadata); err != nil {
 			return fmt.Errorf("[%s] failed to download file %s: %w", game.Name, relPath, err)
 		}
-		downloaded = append(downloaded, localDownload, game.LocalFilePath(relPath))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	affected := relPaths
+	for relPath := range removed {
+		affected = append(affected, relPath)
+	}
+	if _, err := backupLocalFiles(game, mine, affected); err != nil {
+		return err
+	}
+
+	// 여기부터 끊기면 다음에 journal 을 보고 마저 반영함
+	if err := checkRemoteLock(ctx); err != nil {
+		return err
+	}
+	if err := journal.setPhase(journalPhaseApply); err != nil {
+		return err
 	}
 
 	for i := 0; i < len(downloaded); i += 2 {
@@ -43,10 +80,21 @@ func remoteToLocal(
 		}
 	}
 
-	baseFilePath := game.BaseMetaFilePath()
-	if err := remote.Save(baseFilePath); err != nil {
-		return fmt.Errorf("[%s] failed to save metadata for %s: %w", game.Name, baseFilePath, err)
-	}
+	return nil
+}
 
+// applyModifiedTimes 내용은 그대로이고 원격에서 수정 시간만 바뀐 로컬 파일의 수정 시간을 바꿈
+func applyModifiedTimes(game *config.GameConfig, merged, mine filelist.FileList) error {
+	for relPath, metadata := range merged {
+		local, exists := mine[relPath]
+		if !exists || !local.SameContent(metadata) || local.ModifiedTime == metadata.ModifiedTime {
+			continue
+		}
+		log.Printf("[%s] updating modification time of %s\n", game.Name, relPath)
+		modTime := time.Unix(0, metadata.ModifiedTime)
+		if err := os.Chtimes(game.LocalFilePath(relPath), modTime, modTime); err != nil {
+			return fmt.Errorf("[%s] failed to set modification time of %s: %w", game.Name, relPath, err)
+		}
+	}
 	return nil
 }
@@ -13,6 +13,10 @@ import (
 func SyncAll(ctx context.Context) error {
 	log.Println("Starting synchronization of all games...")
 
+	if !isDryRun(ctx) {
+		recoverSyncJournals(ctx, config.Value.Games)
+	}
+
 	var errch = make(chan error, len(config.Value.Games))
 	defer func() {
 		if errch != nil {
@@ -50,3 +54,33 @@ func SyncAll(ctx context.Context) error {
 	log.Println("All games synced successfully.")
 	return nil
 }
+
+// recoverSyncJournals 시작할 때 끊긴 동기화를 모두 정리
+// 이번에 동기화하지 못하는 게임도 반쯤 반영된 채로 남지 않게 하고, 쓰이지 않을 전송 기록을 지움
+// 실패한 게임은 SyncGame 에서 다시 정리를 시도하므로 기록만 함
+func recoverSyncJournals(ctx context.Context, games []*config.GameConfig) {
+	for _, game := range games {
+		if !hasSyncJournal(game) {
+			continue
+		}
+		if err := recoverGame(ctx, game); err != nil {
+			log.Printf("[%s] failed to recover interrupted sync: %+v\n", game.Name, err)
+		}
+	}
+	pruneTransferProgress(games)
+}
+
+func recoverGame(ctx context.Context, game *config.GameConfig) error {
+	backend, err := gameBackend(ctx, game)
+	if err != nil {
+		return err
+	}
+	lock, err := acquireRemoteLock(ctx, game, backend)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+
+	_, err = recoverSyncJournal(lock.ctx, game, backend)
+	return err
+}
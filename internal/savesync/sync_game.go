@@ -2,71 +2,137 @@ package savesync
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log"
+	"os"
 	"scpsave/internal/config"
-	"scpsave/internal/conio"
 	"scpsave/internal/filelist"
-	"scpsave/internal/scp"
-	"time"
+	"scpsave/internal/storage"
 )
 
 func SyncGame(ctx context.Context, game *config.GameConfig, skipDownloadMeta bool) error {
 	log.Println("Syncing game:", game.Name)
 
-	scpclient := scp.ClientFromContext(ctx)
+	backend, err := gameBackend(ctx, game)
+	if err != nil {
+		return err
+	}
+
+	if isDryRun(ctx) {
+		return dryRunSyncGame(ctx, game, backend)
+	}
+
+	// 원격 메타데이터 확인부터 반영까지 다른 컴퓨터가 끼어들지 못하게 잠금
+	lock, err := acquireRemoteLock(ctx, game, backend)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	ctx = lock.ctx
+
+	// 지난번에 끊긴 동기화를 먼저 정리해야 base 와 로컬 파일을 믿을 수 있음
+	staged, err := recoverSyncJournal(ctx, game, backend)
+	if err != nil {
+		return err
+	}
 
 	base, err := filelist.LoadFileList(game.BaseMetaFilePath())
 	if err != nil {
 		return fmt.Errorf("[%s] failed to load base file list: %w", game.Name, err)
 	}
 
-	mine, err := filelist.MakeFileList(game.LocalDir, game.FileRegExp)
+	mine, err := makeLocalFileList(ctx, game)
 	if err != nil {
-		return fmt.Errorf("[%s] failed to make local file list: %w", game.Name, err)
+		return err
 	}
 
 	var remote filelist.FileList
+	var generation string
+	useCache := false
 	if skipDownloadMeta {
+		// 잠금을 잡은 뒤 현재 세대만 읽어서 그 사이 다른 컴퓨터가 올리지 않았을 때만 받아 둔 목록을 씀
+		if useCache, err = isRemoteMetaCached(game, backend); err != nil {
+			return err
+		}
+	}
+	if useCache {
 		remote, err = filelist.LoadFileList(game.RemoteMetaFileLocalPath())
+		if err == nil {
+			err = remote.Validate()
+		}
 		if err != nil {
 			return fmt.Errorf("[%s] failed to load remote file list: %w", game.Name, err)
 		}
+		if generation, err = loadLocalGeneration(game); err != nil {
+			return err
+		}
 	} else {
-		err = scpclient.DownloadFile(ctx, game.RemoteMetaFileRemotePath(), game.RemoteMetaFileLocalPath(), time.Now().UnixNano())
-		if err == nil {
-			remote, err = filelist.LoadFileList(game.RemoteMetaFileLocalPath())
-			if err != nil {
-				return fmt.Errorf("[%s] failed to load remote file list: %w", game.Name, err)
-			}
-		} else if !errors.Is(err, scp.ErrNoSuchFile) {
-			return fmt.Errorf("[%s] failed to download remote file list: %w", game.Name, err)
+		if remote, generation, err = downloadRemoteMeta(ctx, game, backend, game.RemoteMetaFileLocalPath()); err != nil {
+			return err
+		}
+		if err := saveLocalGeneration(game, generation); err != nil {
+			return err
 		}
 	}
 
-	if base.Equal(mine) {
-		if base.Equal(remote) {
-			// 아무것도 안함
-			return nil
-		}
+	if base.Identical(mine) && base.Identical(remote) {
+		// 아무것도 안함
+		return staged.handOverStaging(backend, nil, nil, nil)
+	}
 
-		return remoteToLocal(ctx, game, scpclient, remote, mine)
-	} else {
-		if base.Equal(remote) {
-			return localToRemote(ctx, game, scpclient, mine, remote)
-		}
+	merged, conflicts := mergeFileLists(base, mine, remote)
+	if err := resolveConflicts(game, merged, mine, remote, conflicts); err != nil {
+		return err
+	}
 
-		// 충돌 해결 해야 함
-		switch conio.ResolveConflict(game) {
-		case conio.LocalToRemote:
-			return localToRemote(ctx, game, scpclient, mine, remote)
+	journal := newSyncJournal(game, merged)
+	uploads, downloads, err := plannedTransfers(game, merged, mine, remote)
+	if err != nil {
+		return err
+	}
+	if err := staged.handOverStaging(backend, journal, uploads, downloads); err != nil {
+		return err
+	}
+	// 수정 시간만 바뀐 것도 새 세대로 올려서 다른 컴퓨터가 따르게 함, 내용이 같은 blob 은 다시 올리지 않음
+	if !merged.Identical(remote) {
+		if generation, err = localToRemote(ctx, game, backend, merged, remote, generation, journal); err != nil {
+			return err
+		}
+	}
+	if !merged.Equal(mine) {
+		if err := remoteToLocal(ctx, game, backend, merged, mine, generation, journal); err != nil {
+			return err
+		}
+	}
+	if err := applyModifiedTimes(game, merged, mine); err != nil {
+		return err
+	}
+	// 양쪽이 같게 바뀌었거나 수정 시간만 바뀐 경우에도 base 를 맞춰 둠
+	return journal.finish()
+}
 
-		case conio.RemoteToLocal:
-			return remoteToLocal(ctx, game, scpclient, remote, mine)
+// isRemoteMetaCached 받아 둔 remote.yaml 이 원격의 현재 세대와 같은지
+// 세대가 없는 예전 구조는 바뀌었는지 알 수 없으므로 항상 다시 받음
+func isRemoteMetaCached(game *config.GameConfig, backend storage.Backend) (bool, error) {
+	current, err := readRemoteGeneration(game, backend)
+	if err != nil || current == "" {
+		return false, err
+	}
+	cached, err := loadLocalGeneration(game)
+	if err != nil || cached != current {
+		return false, err
+	}
+	if _, err := os.Stat(game.RemoteMetaFileLocalPath()); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
 
-		default:
-			return fmt.Errorf("[%s] conflict resolution aborted by user", game.Name)
-		}
+// gameBackend 게임이 쓰는 원격의 Backend, 같은 원격을 쓰는 게임끼리 연결을 공유
+func gameBackend(ctx context.Context, game *config.GameConfig) (storage.Backend, error) {
+	backend, err := storage.BackendFromContext(ctx, game.Remote)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] failed to connect to remote %s: %w", game.Name, game.RemoteLabel(), err)
 	}
+	return backend, nil
 }
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package scp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// dialAgent SSH_AUTH_SOCK 의 유닉스 소켓으로 ssh-agent 에 연결
+func dialAgent() (io.ReadWriteCloser, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("ssh-agent is not available: SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent at %s: %w", socket, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,92 @@
+//go:build !windows
+
+package scp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startTestAgent 키 하나를 가진 ssh-agent 를 띄우고 연결이 끊기면 닫히는 채널을 반환
+func startTestAgent(t *testing.T) <-chan struct{} {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	closed := make(chan struct{})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		_ = agent.ServeAgent(keyring, conn)
+		close(closed)
+	}()
+	return closed
+}
+
+func TestAuthBuilderAgent(t *testing.T) {
+	closed := startTestAgent(t)
+
+	b := newAuthBuilder()
+	if _, err := b.authMethods([]string{AuthAgent}, "", ""); err != nil {
+		t.Fatalf("authMethods failed: %v", err)
+	}
+	if b.agent == nil {
+		t.Fatal("ssh-agent was not connected")
+	}
+	signers, err := b.agent.Signers()
+	if err != nil || len(signers) != 1 {
+		t.Fatalf("agent signers = %d, %v, want 1", len(signers), err)
+	}
+
+	// 다른 호스트도 같은 연결을 씀
+	agentConn := b.agentConn
+	if _, err := b.authMethods([]string{AuthAgent}, "", ""); err != nil {
+		t.Fatalf("authMethods failed: %v", err)
+	}
+	if b.agentConn != agentConn {
+		t.Errorf("ssh-agent was connected again")
+	}
+
+	b.close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ssh-agent connection was not closed")
+	}
+}
+
+func TestAuthBuilderWithoutAgent(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	b := newAuthBuilder()
+	methods, err := b.authMethods([]string{AuthAgent}, "", "")
+	if err != nil {
+		t.Fatalf("authMethods failed: %v", err)
+	}
+	if len(methods) != 1 || b.agent != nil || !b.agentFailed {
+		t.Errorf("ssh-agent was not skipped")
+	}
+	b.close()
+}
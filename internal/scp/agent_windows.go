@@ -0,0 +1,35 @@
+//go:build windows
+
+package scp
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// openSSHAgentPipe Windows 의 OpenSSH ssh-agent 서비스가 여는 named pipe
+const openSSHAgentPipe = `\\.\pipe\openssh-ssh-agent`
+
+// dialAgent SSH_AUTH_SOCK 이 없으면 OpenSSH 의 named pipe 로 ssh-agent 에 연결
+// SSH_AUTH_SOCK 이 named pipe 가 아니면 유닉스 소켓으로 봄
+func dialAgent() (io.ReadWriteCloser, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		socket = openSSHAgentPipe
+	}
+
+	var conn io.ReadWriteCloser
+	var err error
+	if strings.HasPrefix(socket, `\\.\pipe\`) {
+		conn, err = os.OpenFile(socket, os.O_RDWR, 0)
+	} else {
+		conn, err = net.Dial("unix", socket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent at %s: %w", socket, err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,164 @@
+package scp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"scpsave/internal/conio"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const (
+	AuthAgent     = "agent"     // SSH_AUTH_SOCK 의 ssh-agent, Windows 에서는 없으면 OpenSSH 의 ssh-agent 서비스
+	AuthPublicKey = "publickey" // private_key_path 의 개인키 (인증서가 있으면 인증서 먼저)
+)
+
+// authBuilder 여러 호스트(점프 호스트 포함)의 인증 방식을 만듦
+// 같은 키의 암호를 여러 번 묻지 않도록 읽은 키와 ssh-agent 연결을 재사용
+type authBuilder struct {
+	agent       agent.ExtendedAgent
+	agentConn   io.Closer
+	agentFailed bool
+	keys        map[string][]ssh.Signer
+}
+
+func newAuthBuilder() *authBuilder {
+	return &authBuilder{keys: make(map[string][]ssh.Signer)}
+}
+
+// authMethods 설정된 순서대로 서명자를 제공하는 인증 방식을 만듦
+// ssh 패키지는 같은 이름의 인증 방식을 한 번만 시도하므로 하나의 콜백으로 묶음
+func (b *authBuilder) authMethods(methods []string, privateKeyPath, certificatePath string) ([]ssh.AuthMethod, error) {
+	if len(methods) == 0 {
+		methods = []string{AuthPublicKey}
+	}
+
+	var sources []func() ([]ssh.Signer, error)
+	for _, method := range methods {
+		switch method {
+		case AuthAgent:
+			// ssh-agent 가 없으면 (Windows 에서 흔함) 건너뛰고 다음 방식을 씀
+			if b.agent == nil && !b.agentFailed {
+				agentConn, err := dialAgent()
+				if err != nil {
+					log.Printf("skipping ssh-agent authentication: %+v\n", err)
+					b.agentFailed = true
+					continue
+				}
+				b.agent = agent.NewClient(agentConn)
+				b.agentConn = agentConn
+			}
+			if b.agent != nil {
+				sources = append(sources, b.agent.Signers)
+			}
+
+		case AuthPublicKey:
+			key := privateKeyPath + "\n" + certificatePath
+			signers, ok := b.keys[key]
+			if !ok {
+				var err error
+				signers, err = loadKeySigners(privateKeyPath, certificatePath)
+				if err != nil {
+					return nil, err
+				}
+				b.keys[key] = signers
+			}
+			sources = append(sources, func() ([]ssh.Signer, error) { return signers, nil })
+
+		default:
+			return nil, fmt.Errorf("unsupported auth method '%s'", method)
+		}
+	}
+
+	return []ssh.AuthMethod{
+		ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			var all []ssh.Signer
+			var errs []error
+			for _, source := range sources {
+				signers, err := source()
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				all = append(all, signers...)
+			}
+			if len(all) == 0 && len(errs) > 0 {
+				return nil, errors.Join(errs...)
+			}
+			return all, nil
+		}),
+	}, nil
+}
+
+// close ssh-agent 연결을 닫음, 클라이언트를 닫을 때 부름
+func (b *authBuilder) close() {
+	if b.agentConn != nil {
+		_ = b.agentConn.Close()
+		b.agentConn = nil
+		b.agent = nil
+	}
+}
+
+func loadKeySigners(privateKeyPath, certificatePath string) ([]ssh.Signer, error) {
+	if privateKeyPath == "" {
+		return nil, errors.New("private key path is empty")
+	}
+
+	bt, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key %s: %w", privateKeyPath, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(bt)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := conio.ReadPassphrase(privateKeyPath)
+		if perr != nil {
+			return nil, fmt.Errorf("failed to read passphrase for %s: %w", privateKeyPath, perr)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(bt, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", privateKeyPath, err)
+	}
+
+	// OpenSSH 와 같이 지정하지 않으면 "<key>-cert.pub" 가 있는 경우 사용
+	if certificatePath == "" {
+		if _, err := os.Stat(privateKeyPath + "-cert.pub"); err == nil {
+			certificatePath = privateKeyPath + "-cert.pub"
+		}
+	}
+	if certificatePath == "" {
+		return []ssh.Signer{signer}, nil
+	}
+
+	certSigner, err := loadCertSigner(certificatePath, signer)
+	if err != nil {
+		return nil, err
+	}
+	return []ssh.Signer{certSigner, signer}, nil
+}
+
+func loadCertSigner(certificatePath string, signer ssh.Signer) (ssh.Signer, error) {
+	bt, err := os.ReadFile(certificatePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", certificatePath, err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(bt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", certificatePath, err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an SSH certificate", certificatePath)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("certificate %s does not match the private key: %w", certificatePath, err)
+	}
+	return certSigner, nil
+}
@@ -0,0 +1,249 @@
+package scp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultKeepAliveInterval = 30 * time.Second
+	DefaultReconnectAttempts = 5
+
+	// 서버의 MaxSessions 기본값(10)을 넘지 않도록 한 연결에서 동시에 실행할 작업 수
+	maxConcurrentOps = 8
+
+	dialTimeout       = 15 * time.Second
+	pingTimeout       = 10 * time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+var (
+	ErrClientClosed = errors.New("client is closed")
+)
+
+type hop struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// conn 하나의 SSH 연결과 그 위의 원격 파일 작업
+type conn struct {
+	sshClient *ssh.Client
+	jumps     []*ssh.Client // 서버까지 거쳐온 점프 호스트 연결
+	fs        remoteFS
+	done      chan struct{} // 연결이 끊어지면 닫힘
+	broken    atomic.Bool
+	closeOnce sync.Once
+}
+
+func (cn *conn) alive() bool {
+	if cn.broken.Load() {
+		return false
+	}
+	select {
+	case <-cn.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (cn *conn) close() {
+	cn.closeOnce.Do(func() {
+		cn.broken.Store(true)
+		cn.fs.Close()
+		cn.sshClient.Close()
+		closeClients(cn.jumps)
+	})
+}
+
+func (cn *conn) ping(timeout time.Duration) error {
+	errch := make(chan error, 1)
+	go func() {
+		_, _, err := cn.sshClient.SendRequest("keepalive@openssh.com", true, nil)
+		errch <- err
+	}()
+
+	select {
+	case err := <-errch:
+		return err
+	case <-cn.done:
+		return errors.New("connection closed")
+	case <-time.After(timeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+// dialHops 각 호스트를 앞 호스트의 연결을 통해 차례로 접속
+func dialHops(hops []hop) ([]*ssh.Client, error) {
+	clients := make([]*ssh.Client, 0, len(hops))
+	for i, h := range hops {
+		if i == 0 {
+			client, err := ssh.Dial("tcp", h.addr, h.config)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to SSH server %s: %w", h.addr, err)
+			}
+			clients = append(clients, client)
+			continue
+		}
+
+		via := hops[i-1].addr
+		netConn, err := clients[i-1].Dial("tcp", h.addr)
+		if err != nil {
+			closeClients(clients)
+			return nil, fmt.Errorf("failed to connect to SSH server %s via %s: %w", h.addr, via, err)
+		}
+		sshConn, chans, reqs, err := ssh.NewClientConn(netConn, h.addr, h.config)
+		if err != nil {
+			netConn.Close()
+			closeClients(clients)
+			return nil, fmt.Errorf("failed to connect to SSH server %s via %s: %w", h.addr, via, err)
+		}
+		clients = append(clients, ssh.NewClient(sshConn, chans, reqs))
+	}
+	return clients, nil
+}
+
+// closeClients 나중에 연결한 것부터 닫음
+func closeClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
+
+func (c *Client) dial() (*conn, error) {
+	clients, err := dialHops(c.hops)
+	if err != nil {
+		return nil, err
+	}
+	sshClient := clients[len(clients)-1]
+	jumps := clients[:len(clients)-1]
+
+	fs, err := newRemoteFS(c.opts.Protocol, sshClient)
+	if err != nil {
+		closeClients(clients)
+		return nil, err
+	}
+
+	cn := &conn{
+		sshClient: sshClient,
+		jumps:     jumps,
+		fs:        fs,
+		done:      make(chan struct{}),
+	}
+	go func() {
+		_ = sshClient.Wait()
+		close(cn.done)
+	}()
+	go c.keepAlive(cn)
+	return cn, nil
+}
+
+func (c *Client) keepAlive(cn *conn) {
+	if c.opts.KeepAliveInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.opts.KeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cn.done:
+			return
+		case <-ticker.C:
+			if err := cn.ping(pingTimeout); err != nil {
+				log.Printf("SSH keepalive to %s failed, dropping connection: %+v\n", c.opts.ServerAddress, err)
+				cn.close()
+				return
+			}
+		}
+	}
+}
+
+// getConn 현재 연결을 반환, 끊어진 경우 다시 연결
+func (c *Client) getConn(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+	if c.conn != nil && c.conn.alive() {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+
+	cn, err := c.reconnect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = cn
+	return cn, nil
+}
+
+func (c *Client) reconnect(ctx context.Context) (*conn, error) {
+	attempts := max(c.opts.ReconnectAttempts, 1)
+	delay := time.Second
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		log.Printf("connecting to %s (attempt %d/%d)...\n", c.opts.ServerAddress, attempt, attempts)
+		cn, err := c.dial()
+		if err == nil {
+			return cn, nil
+		}
+		if errors.Is(err, ErrHostKeyMismatch) || errors.Is(err, ErrHostKeyRejected) {
+			return nil, err
+		}
+		lastErr = err
+		log.Printf("failed to connect to %s: %+v\n", c.opts.ServerAddress, err)
+
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+	return nil, fmt.Errorf("failed to reconnect after %d attempts: %w", attempts, lastErr)
+}
+
+// do 원격 작업을 실행하고, 연결이 끊어져서 실패했다면 다시 연결해서 한 번 더 시도
+func (c *Client) do(ctx context.Context, op func(fs remoteFS) error) error {
+	c.ops.Acquire()
+	defer c.ops.Release()
+
+	cn, err := c.getConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = op(cn.fs)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if cn.alive() && cn.ping(pingTimeout) == nil {
+		return err // 연결은 정상, 작업 자체의 실패
+	}
+
+	log.Printf("connection to %s lost: %+v\n", c.opts.ServerAddress, err)
+	cn.close()
+	cn, reconnErr := c.getConn(ctx)
+	if reconnErr != nil {
+		return fmt.Errorf("%w (reconnect failed: %v)", err, reconnErr)
+	}
+	return op(cn.fs)
+}
@@ -0,0 +1,154 @@
+package scp
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"scpsave/internal/conio"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	ErrHostKeyMismatch = errors.New("remote host key does not match known_hosts")
+	ErrHostKeyRejected = errors.New("remote host key was not trusted")
+)
+
+func newHostKeyCallback(knownHostsPath string) (ssh.HostKeyCallback, error) {
+	knownHostsPath = filepath.Clean(knownHostsPath)
+	if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create known_hosts directory for %s: %w", knownHostsPath, err)
+	}
+	f, err := os.OpenFile(knownHostsPath, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known_hosts file %s: %w", knownHostsPath, err)
+	}
+	f.Close()
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		// 처음 접속한 호스트가 추가될 수 있으므로 매번 다시 읽음
+		check, err := knownhosts.New(knownHostsPath)
+		if err != nil {
+			return fmt.Errorf("failed to load known_hosts file %s: %w", knownHostsPath, err)
+		}
+
+		err = check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		// 같은 종류의 키가 기록되어 있을 때만 바뀐 것으로 봄, 다른 종류는 아직 모르는 키
+		fingerprint := ssh.FingerprintSHA256(key)
+		if slices.ContainsFunc(keyErr.Want, func(want knownhosts.KnownKey) bool { return want.Key.Type() == key.Type() }) {
+			known := make([]string, 0, len(keyErr.Want))
+			for _, want := range keyErr.Want {
+				known = append(known, fmt.Sprintf("%s %s (%s:%d)", want.Key.Type(), ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+			}
+			return fmt.Errorf("%w: %s presented %s %s, expected %s",
+				ErrHostKeyMismatch, hostname, key.Type(), fingerprint, strings.Join(known, ", "))
+		}
+
+		if !conio.ConfirmHostKey(hostname, key.Type(), fingerprint) {
+			return fmt.Errorf("%w: %s %s %s", ErrHostKeyRejected, hostname, key.Type(), fingerprint)
+		}
+
+		if err := appendKnownHost(knownHostsPath, hostname, remote, key); err != nil {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// probeHostKey known_hosts 에서 호스트의 키를 찾기 위한 어디에도 없는 키
+var probeHostKey, _ = ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+
+// knownHostKeyAlgorithms known_hosts 에 addr 의 키가 있으면 그 종류의 알고리즘을 먼저 요청하도록 함
+// OpenSSH 와 같이 처리하지 않으면 서버가 다른 종류의 키를 보내 키가 바뀐 것처럼 보임
+// 기록이 없거나 인증 기관(@cert-authority)으로 확인하는 호스트는 nil (기본값)
+func knownHostKeyAlgorithms(knownHostsPath, addr string) []string {
+	knownHostsPath = filepath.Clean(knownHostsPath)
+	check, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(addr, &net.TCPAddr{}, probeHostKey), &keyErr) || len(keyErr.Want) == 0 {
+		return nil
+	}
+
+	caLines, err := certAuthorityLines(knownHostsPath)
+	if err != nil {
+		return nil
+	}
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		if caLines[want.Line] {
+			return nil
+		}
+		switch want.Key.Type() {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		default:
+			algorithms = append(algorithms, want.Key.Type())
+		}
+	}
+	algorithms = append(algorithms, ssh.SupportedAlgorithms().HostKeys...)
+
+	var unique []string
+	for _, algorithm := range algorithms {
+		if !slices.Contains(unique, algorithm) {
+			unique = append(unique, algorithm)
+		}
+	}
+	return unique
+}
+
+// certAuthorityLines @cert-authority 로 시작하는 줄 번호
+func certAuthorityLines(knownHostsPath string) (map[int]bool, error) {
+	f, err := os.Open(knownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make(map[int]bool)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "@cert-authority") {
+			lines[n] = true
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func appendKnownHost(knownHostsPath, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if remote != nil {
+		if addr := knownhosts.Normalize(remote.String()); addr != addresses[0] {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	f, err := os.OpenFile(knownHostsPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known_hosts file %s: %w", knownHostsPath, err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, knownhosts.Line(addresses, key)); err != nil {
+		return fmt.Errorf("failed to write known_hosts file %s: %w", knownHostsPath, err)
+	}
+	return f.Close()
+}
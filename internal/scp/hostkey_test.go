@@ -0,0 +1,161 @@
+package scp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var testHostAddr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2222}
+
+const testHostname = "127.0.0.1:2222"
+
+func newEd25519HostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newRSAHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writeKnownHosts(t *testing.T, lines ...string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := os.WriteFile(p, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// answerStdin 확인 질문에 answer 로 답하게 함
+func answerStdin(t *testing.T, answer string) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString(answer); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	t.Cleanup(func() {
+		os.Stdin = stdin
+		r.Close()
+	})
+}
+
+func TestHostKeyCallbackKnownKey(t *testing.T) {
+	key := newEd25519HostKey(t)
+	p := writeKnownHosts(t, knownhosts.Line([]string{knownhosts.Normalize(testHostname)}, key))
+
+	callback, err := newHostKeyCallback(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback(testHostname, testHostAddr, key); err != nil {
+		t.Errorf("known key was rejected: %v", err)
+	}
+}
+
+func TestHostKeyCallbackChangedKey(t *testing.T) {
+	p := writeKnownHosts(t, knownhosts.Line([]string{knownhosts.Normalize(testHostname)}, newEd25519HostKey(t)))
+
+	callback, err := newHostKeyCallback(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback(testHostname, testHostAddr, newEd25519HostKey(t)); !errors.Is(err, ErrHostKeyMismatch) {
+		t.Errorf("changed key = %v, want ErrHostKeyMismatch", err)
+	}
+}
+
+func TestHostKeyCallbackTrustOnFirstUse(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new", "known_hosts")
+	callback, err := newHostKeyCallback(p)
+	if err != nil {
+		t.Fatalf("newHostKeyCallback did not create known_hosts: %v", err)
+	}
+
+	key := newEd25519HostKey(t)
+	answerStdin(t, "n\n")
+	if err := callback(testHostname, testHostAddr, key); !errors.Is(err, ErrHostKeyRejected) {
+		t.Fatalf("rejected key = %v, want ErrHostKeyRejected", err)
+	}
+
+	answerStdin(t, "y\n")
+	if err := callback(testHostname, testHostAddr, key); err != nil {
+		t.Fatalf("trusted key was rejected: %v", err)
+	}
+	// 기록한 뒤에는 묻지 않음
+	answerStdin(t, "")
+	if err := callback(testHostname, testHostAddr, key); err != nil {
+		t.Errorf("recorded key was rejected: %v", err)
+	}
+}
+
+func TestHostKeyCallbackOtherKeyType(t *testing.T) {
+	p := writeKnownHosts(t, knownhosts.Line([]string{knownhosts.Normalize(testHostname)}, newRSAHostKey(t)))
+	callback, err := newHostKeyCallback(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 다른 종류의 키는 바뀐 것이 아니라 아직 모르는 키
+	answerStdin(t, "n\n")
+	if err := callback(testHostname, testHostAddr, newEd25519HostKey(t)); !errors.Is(err, ErrHostKeyRejected) {
+		t.Errorf("key of another type = %v, want ErrHostKeyRejected", err)
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	rsaLine := knownhosts.Line([]string{knownhosts.Normalize(testHostname)}, newRSAHostKey(t))
+	algorithms := knownHostKeyAlgorithms(writeKnownHosts(t, rsaLine), testHostname)
+	if len(algorithms) < 2 || algorithms[0] != ssh.KeyAlgoRSASHA512 || algorithms[1] != ssh.KeyAlgoRSASHA256 {
+		t.Errorf("algorithms = %v, want RSA first", algorithms)
+	}
+
+	if algorithms := knownHostKeyAlgorithms(writeKnownHosts(t), testHostname); algorithms != nil {
+		t.Errorf("algorithms for an unknown host = %v, want nil", algorithms)
+	}
+
+	caLine := "@cert-authority * " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(newEd25519HostKey(t))))
+	if algorithms := knownHostKeyAlgorithms(writeKnownHosts(t, caLine), testHostname); algorithms != nil {
+		t.Errorf("algorithms for a host checked by a CA = %v, want nil", algorithms)
+	}
+}
@@ -0,0 +1,71 @@
+package scp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrInvalidRemotePath = errors.New("invalid remote path")
+)
+
+// shellQuote POSIX 셸에서 문자 그대로 해석되도록 작은따옴표로 감쌈
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteRemotePath 원격 명령에 넣을 경로를 검사하고 인용
+// 제어 문자는 셸 출력 해석을 깨뜨리므로 허용하지 않음
+// '-' 로 시작하면 옵션으로 해석되지 않도록 "./" 를 붙임
+func quoteRemotePath(remotePath string) (string, error) {
+	if remotePath == "" {
+		return "", fmt.Errorf("%w: empty path", ErrInvalidRemotePath)
+	}
+	for _, r := range remotePath {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("%w: control character %U in %q", ErrInvalidRemotePath, r, remotePath)
+		}
+	}
+	if strings.HasPrefix(remotePath, "-") {
+		remotePath = "./" + remotePath
+	}
+	return shellQuote(remotePath), nil
+}
+
+// scpSafePath go-scp 는 원격 경로를 큰따옴표로 감싸서 scp 명령에 넣으므로
+// 큰따옴표 안에서 셸이 해석하는 문자가 없고 옵션으로 보이지 않는 경로만 그대로 넘길 수 있음
+func scpSafePath(remotePath string) bool {
+	if _, err := quoteRemotePath(remotePath); err != nil {
+		return false
+	}
+	return !strings.HasPrefix(remotePath, "-") && !strings.ContainsAny(remotePath, "$`\\\"")
+}
+
+// scpTempPath scpSafePath 가 아닌 경로를 scp 로 주고받을 때 거치는 임시 파일
+// 경로를 해시한 이름을 안전한 가장 가까운 상위 디렉터리에 둠
+func scpTempPath(remotePath, suffix string) string {
+	dir := path.Dir(remotePath)
+	for !scpSafePath(dir) && dir != path.Dir(dir) {
+		dir = path.Dir(dir)
+	}
+	sum := sha256.Sum256([]byte(remotePath))
+	return path.Join(dir, ".scpsave-"+hex.EncodeToString(sum[:8])+suffix)
+}
+
+// remoteCommand format 의 %s 를 인용된 경로들로 채움
+func remoteCommand(format string, remotePaths ...string) (string, error) {
+	args := make([]any, 0, len(remotePaths))
+	for _, remotePath := range remotePaths {
+		quoted, err := quoteRemotePath(remotePath)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, quoted)
+	}
+	return fmt.Sprintf(format, args...), nil
+}
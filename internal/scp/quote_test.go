@@ -0,0 +1,202 @@
+package scp
+
+import (
+	"errors"
+	"os/exec"
+	"path"
+	"testing"
+)
+
+var hostileNames = []string{
+	"save.dat",
+	"it's.sav",
+	`say "hi".sav`,
+	"$(rm -rf ~).sav",
+	"`reboot`.sav",
+	"${HOME}.sav",
+	"a b;c&d|e.sav",
+	`back\slash.sav`,
+	"*.sav",
+	"-rf",
+	"'",
+	"''",
+	"한글 세이브.sav",
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", `''`},
+		{"save.dat", `'save.dat'`},
+		{"it's.sav", `'it'\''s.sav'`},
+		{`say "hi".sav`, `'say "hi".sav'`},
+		{"$(rm -rf ~).sav", `'$(rm -rf ~).sav'`},
+		{"`reboot`.sav", "'`reboot`.sav'"},
+		{"'", `''\'''`},
+		{"-rf", `'-rf'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestShellQuoteRoundTrip 실제 셸이 인용된 문자열을 원래 값 그대로 돌려주는지
+func TestShellQuoteRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	for _, name := range hostileNames {
+		out, err := exec.Command(sh, "-c", "printf '%s' "+shellQuote(name)).Output()
+		if err != nil {
+			t.Fatalf("sh failed for %q: %v", name, err)
+		}
+		if string(out) != name {
+			t.Errorf("sh printed %q for %q", out, name)
+		}
+	}
+}
+
+func TestQuoteRemotePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		invalid bool
+	}{
+		{in: "saves/game.sav", want: `'saves/game.sav'`},
+		{in: "/abs/it's.sav", want: `'/abs/it'\''s.sav'`},
+		{in: "$(id)/`id`", want: "'$(id)/`id`'"},
+		{in: "-rf", want: `'./-rf'`},
+		{in: "--help/x.sav", want: `'./--help/x.sav'`},
+		{in: "a/-b", want: `'a/-b'`},
+		{in: "", invalid: true},
+		{in: "line\nbreak.sav", invalid: true},
+		{in: "carriage\r.sav", invalid: true},
+		{in: "tab\t.sav", invalid: true},
+		{in: "nul\x00.sav", invalid: true},
+		{in: "esc\x1b[31m.sav", invalid: true},
+		{in: "del\x7f.sav", invalid: true},
+		{in: "c1\u0085.sav", invalid: true},
+	}
+	for _, tt := range tests {
+		got, err := quoteRemotePath(tt.in)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidRemotePath) {
+				t.Errorf("quoteRemotePath(%q) = %s, %v, want ErrInvalidRemotePath", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("quoteRemotePath(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("quoteRemotePath(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	tests := []struct {
+		format  string
+		paths   []string
+		want    string
+		invalid bool
+	}{
+		{format: "cat > %s", paths: []string{"a'b"}, want: `cat > 'a'\''b'`},
+		{format: "mv -f %s %s", paths: []string{"-x", "$(y)"}, want: `mv -f './-x' '$(y)'`},
+		{format: "rm -f %s", paths: []string{"`z`;echo"}, want: "rm -f '`z`;echo'"},
+		{format: "test -e %s", paths: []string{`"q"`}, want: `test -e '"q"'`},
+		{format: "mv -f %s %s", paths: []string{"ok", "bad\n"}, invalid: true},
+		{format: "rm -f %s", paths: []string{""}, invalid: true},
+	}
+	for _, tt := range tests {
+		got, err := remoteCommand(tt.format, tt.paths...)
+		if tt.invalid {
+			if !errors.Is(err, ErrInvalidRemotePath) {
+				t.Errorf("remoteCommand(%q, %q) = %s, %v, want ErrInvalidRemotePath", tt.format, tt.paths, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("remoteCommand(%q, %q) failed: %v", tt.format, tt.paths, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("remoteCommand(%q, %q) = %s, want %s", tt.format, tt.paths, got, tt.want)
+		}
+	}
+}
+
+// TestRemoteCommandRoundTrip 실제 셸에서 인자 하나로, 옵션이 아닌 경로로 전달되는지
+func TestRemoteCommandRoundTrip(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	for _, name := range hostileNames {
+		command, err := remoteCommand(`printf '%%s|' %s %s`, name, name)
+		if err != nil {
+			t.Fatalf("remoteCommand failed for %q: %v", name, err)
+		}
+		out, err := exec.Command(sh, "-c", command).Output()
+		if err != nil {
+			t.Fatalf("sh failed for %q: %v", name, err)
+		}
+		want := name
+		if want[0] == '-' {
+			want = "./" + want
+		}
+		if string(out) != want+"|"+want+"|" {
+			t.Errorf("sh printed %q for %q", out, name)
+		}
+	}
+}
+
+func TestSCPSafePath(t *testing.T) {
+	tests := map[string]bool{
+		"/srv/saves/blobs/g/ab/abcd":   true,
+		"/srv/saves/upload/g/it's.sav": true,
+		"/srv/saves/한글 세이브.sav":        true,
+		"/srv/saves/$(id).sav":         false,
+		"/srv/saves/`id`.sav":          false,
+		`/srv/saves/"q".sav`:           false,
+		`/srv/saves/back\slash.sav`:    false,
+		"-rf":                          false,
+		"/srv/line\nbreak.sav":         false,
+		"":                             false,
+	}
+	for remotePath, want := range tests {
+		if got := scpSafePath(remotePath); got != want {
+			t.Errorf("scpSafePath(%q) = %v, want %v", remotePath, got, want)
+		}
+	}
+}
+
+func TestSCPTempPath(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantDir string
+	}{
+		{"/srv/saves/upload/g/a.sav", "/srv/saves/upload/g"},
+		{"/srv/saves/upload/g/$(id)/a.sav", "/srv/saves/upload/g"},
+		{"/srv/saves/upload/g/`x`/\"y\"/a.sav", "/srv/saves/upload/g"},
+		{"/$(id)/a.sav", "/"},
+	}
+	for _, tt := range tests {
+		got := scpTempPath(tt.in, ".part")
+		if !scpSafePath(got) {
+			t.Errorf("scpTempPath(%q) = %q is not safe for scp", tt.in, got)
+		}
+		if path.Dir(got) != tt.wantDir {
+			t.Errorf("scpTempPath(%q) = %q, want it in %s", tt.in, got, tt.wantDir)
+		}
+		if other := scpTempPath(tt.in+"x", ".part"); other == got {
+			t.Errorf("scpTempPath gave the same path %q for different files", got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package scp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"scpsave/internal/storage"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	ProtocolSCP  = "scp"
+	ProtocolSFTP = "sftp"
+)
+
+// remoteFS 원격 서버의 파일 작업
+// 경로가 없는 경우 os.ErrNotExist 로 판별할 수 있는 에러를 반환해야 함
+type remoteFS interface {
+	WriteFile(ctx context.Context, remotePath string, r io.Reader, size int64) error
+	// CreateFile 파일이 이미 있으면 os.ErrExist 로 판별할 수 있는 에러를 반환
+	CreateFile(remotePath string, r io.Reader) error
+	AppendFile(ctx context.Context, remotePath string, r io.Reader, size int64) error
+	ReadFile(ctx context.Context, remotePath string, offset int64, w io.Writer) error
+	Truncate(remotePath string, size int64) error
+	Remove(remotePath string) error
+	RemoveAll(remoteDir string) error
+	Rename(oldRemotePath, newRemotePath string) error
+	Link(oldRemotePath, newRemotePath string) error
+	MkdirAll(remoteDir string) error
+	Stat(remotePath string) (*storage.FileInfo, error)
+	List(remoteDir string) ([]*storage.FileInfo, error)
+	Close()
+}
+
+func newRemoteFS(protocol string, sshClient *ssh.Client) (remoteFS, error) {
+	switch protocol {
+	case "", ProtocolSCP:
+		return newShellFS(sshClient)
+	case ProtocolSFTP:
+		return newSFTPFS(sshClient)
+	default:
+		return nil, fmt.Errorf("unsupported remote protocol '%s'", protocol)
+	}
+}
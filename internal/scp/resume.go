@@ -0,0 +1,170 @@
+package scp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"scpsave/internal/gzipio"
+	"scpsave/internal/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+const transferChunkSize = 4 << 20
+
+// transferProgress 중단된 전송을 이어서 하기 위한 기록
+// 업로드는 압축된 내용의 해시와 크기, 다운로드는 원격 파일의 크기와 수정 시간으로 같은 파일인지 판단
+type transferProgress struct {
+	Hash    string `yaml:"hash,omitempty"`
+	Size    int64  `yaml:"size"`
+	ModTime int64  `yaml:"mod_time,omitempty"`
+	Offset  int64  `yaml:"offset"`
+}
+
+func (c *Client) progressPath(kind, key string) string {
+	if c.opts.TransferStateDir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(kind + "\n" + key))
+	return filepath.Join(c.opts.TransferStateDir, kind+"-"+hex.EncodeToString(sum[:16])+".yaml")
+}
+
+func loadProgress(progressPath string) *transferProgress {
+	if progressPath == "" {
+		return nil
+	}
+	bt, err := os.ReadFile(progressPath)
+	if err != nil {
+		return nil
+	}
+	var progress transferProgress
+	if err := yaml.Unmarshal(bt, &progress); err != nil {
+		return nil
+	}
+	return &progress
+}
+
+func saveProgress(progressPath string, progress *transferProgress) {
+	if progressPath == "" {
+		return
+	}
+	bt, err := yaml.Marshal(progress)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(progressPath), 0755); err == nil {
+			err = os.WriteFile(progressPath, bt, 0644)
+		}
+	}
+	if err != nil {
+		log.Printf("failed to save transfer progress %s: %+v\n", progressPath, err)
+	}
+}
+
+func removeProgress(progressPath string) {
+	if progressPath == "" {
+		return
+	}
+	if err := os.Remove(progressPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed to remove transfer progress %s: %+v\n", progressPath, err)
+	}
+}
+
+// resumeUploadOffset 원격 임시 파일에서 이어서 올릴 위치
+// 기록된 위치를 넘어선 부분은 확인되지 않았으므로 잘라냄
+func resumeUploadOffset(fs remoteFS, tempfile, progressPath string, r *gzipio.CompressReader) int64 {
+	progress := loadProgress(progressPath)
+	if progress == nil || progress.Hash != r.CompressedHash() || progress.Size != r.Size() {
+		return 0
+	}
+	info, err := fs.Stat(tempfile)
+	if err != nil {
+		return 0
+	}
+
+	offset := min(progress.Offset, info.Size)
+	if info.Size > offset {
+		if err := fs.Truncate(tempfile, offset); err != nil {
+			log.Printf("failed to truncate %s, restarting upload: %+v\n", tempfile, err)
+			return 0
+		}
+	}
+	if offset > 0 {
+		log.Printf("resuming upload of %s from %d/%d bytes\n", tempfile, offset, r.Size())
+	}
+	return offset
+}
+
+// resumeDownloadOffset 로컬 임시 파일에서 이어서 받을 위치
+// 원격 파일이 바뀌었으면 처음부터 다시 받고, 기록된 위치를 넘어선 부분은 디스크에 반영됐는지 모르므로 잘라냄
+func resumeDownloadOffset(partial, progressPath string, info *storage.FileInfo) int64 {
+	var offset int64
+	progress := loadProgress(progressPath)
+	if progress != nil && progress.Size == info.Size && progress.ModTime == info.ModTime.UnixNano() {
+		if st, err := os.Stat(partial); err == nil {
+			offset = min(progress.Offset, st.Size(), info.Size)
+		}
+	}
+
+	if err := os.Truncate(partial, offset); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed to truncate %s, restarting download: %+v\n", partial, err)
+		_ = os.Remove(partial)
+		offset = 0
+	}
+	if offset > 0 {
+		log.Printf("resuming download of %s from %d/%d bytes\n", partial, offset, info.Size)
+	}
+	return offset
+}
+
+// resumeDecompress 이미 받은 부분을 먼저 풀어 두고 그 뒤로 f 에 이어서 쓸 수 있게 함
+// 받은 부분을 풀 수 없으면 처음부터 다시 받음
+func resumeDecompress(f *os.File, tempfile string, progress *transferProgress) (*gzipio.DecompressWriter, error) {
+	dw := gzipio.NewDecompressWriter(tempfile)
+	if progress.Offset > 0 {
+		if _, err := io.Copy(dw, io.NewSectionReader(f, 0, progress.Offset)); err != nil {
+			dw.CloseWithError(err)
+			log.Printf("failed to decompress %s, restarting download: %+v\n", f.Name(), err)
+			if err := f.Truncate(0); err != nil {
+				return nil, err
+			}
+			progress.Offset = 0
+			dw = gzipio.NewDecompressWriter(tempfile)
+		}
+	}
+	if _, err := f.Seek(progress.Offset, io.SeekStart); err != nil {
+		dw.CloseWithError(err)
+		return nil, err
+	}
+	return dw, nil
+}
+
+// progressWriter 받은 내용을 로컬 임시 파일에 쓰고 transferChunkSize 마다 디스크에 반영한 뒤 위치를 기록
+type progressWriter struct {
+	f            *os.File
+	progressPath string
+	progress     *transferProgress
+	unsaved      int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.progress.Offset += int64(n)
+	w.unsaved += int64(n)
+	if err == nil && w.unsaved >= transferChunkSize {
+		err = w.save()
+	}
+	return n, err
+}
+
+// save 기록한 위치까지는 디스크에 있어야 이어받을 때 믿을 수 있음
+func (w *progressWriter) save() error {
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	saveProgress(w.progressPath, w.progress)
+	w.unsaved = 0
+	return nil
+}
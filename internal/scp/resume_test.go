@@ -0,0 +1,118 @@
+package scp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"scpsave/internal/storage"
+	"testing"
+	"time"
+)
+
+func gzipBytes(t *testing.T, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResumeDownloadOffsetUsesRecordedOffset(t *testing.T) {
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "a.sav.download.gz")
+	progressPath := filepath.Join(dir, "progress.yaml")
+	info := &storage.FileInfo{Size: 100, ModTime: time.Unix(1000, 0)}
+
+	// 기록된 위치 뒤에 쓰다 만 내용이 남은 경우
+	if err := os.WriteFile(partial, make([]byte, 60), 0644); err != nil {
+		t.Fatal(err)
+	}
+	saveProgress(progressPath, &transferProgress{Size: 100, ModTime: info.ModTime.UnixNano(), Offset: 40})
+	if offset := resumeDownloadOffset(partial, progressPath, info); offset != 40 {
+		t.Errorf("offset = %d, want the recorded 40", offset)
+	}
+	if st, err := os.Stat(partial); err != nil || st.Size() != 40 {
+		t.Errorf("partial was not truncated to the recorded offset: %v, %v", st, err)
+	}
+
+	// 원격 파일이 바뀌면 처음부터 받음
+	changed := &storage.FileInfo{Size: 100, ModTime: time.Unix(2000, 0)}
+	if offset := resumeDownloadOffset(partial, progressPath, changed); offset != 0 {
+		t.Errorf("offset = %d after the remote file changed, want 0", offset)
+	}
+}
+
+func TestResumeDecompress(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("save data "), 1000)
+	compressed := gzipBytes(t, content)
+	half := int64(len(compressed) / 2)
+
+	partial := filepath.Join(dir, "a.sav.download.gz")
+	tempfile := filepath.Join(dir, "a.sav.download")
+	if err := os.WriteFile(partial, compressed[:half], 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(partial, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	progress := &transferProgress{Offset: half}
+	dw, err := resumeDecompress(f, tempfile, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Offset != half {
+		t.Fatalf("offset = %d, want %d", progress.Offset, half)
+	}
+	if _, err := f.Write(compressed[half:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dw.Write(compressed[half:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if got, _ := os.ReadFile(tempfile); !bytes.Equal(got, content) {
+		t.Errorf("resumed download has different content")
+	}
+	if got, _ := os.ReadFile(partial); !bytes.Equal(got, compressed) {
+		t.Errorf("partial file was not continued at the offset")
+	}
+}
+
+func TestResumeDecompressRestartsCorruptPartial(t *testing.T) {
+	dir := t.TempDir()
+	partial := filepath.Join(dir, "a.sav.download.gz")
+	corrupt := bytes.Repeat([]byte("not gzip "), 2000)
+	if err := os.WriteFile(partial, corrupt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(partial, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	progress := &transferProgress{Offset: int64(len(corrupt))}
+	dw, err := resumeDecompress(f, filepath.Join(dir, "a.sav.download"), progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dw.Close()
+	if progress.Offset != 0 {
+		t.Errorf("offset = %d, want a restart from 0", progress.Offset)
+	}
+	if st, _ := f.Stat(); st.Size() != 0 {
+		t.Errorf("corrupt partial was not truncated")
+	}
+}
@@ -1,6 +1,7 @@
 package scp
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -10,72 +11,177 @@ import (
 	"path"
 	"path/filepath"
 	"scpsave/internal/gzipio"
-	"strings"
+	"scpsave/internal/sem"
+	"scpsave/internal/storage"
+	"sync"
 	"time"
 
-	goscp "github.com/bramvdbogaerde/go-scp"
-	"github.com/bramvdbogaerde/go-scp/auth"
 	"golang.org/x/crypto/ssh"
 )
 
-var (
-	ErrNoSuchFile = errors.New("no such file or directory")
-)
-
 type Client struct {
-	scpClient *goscp.Client
+	opts Options
+	hops []hop // 점프 호스트를 거치는 순서대로, 마지막이 서버
+	auth *authBuilder
+	ops  *sem.Semaphore
+
+	mu     sync.Mutex
+	conn   *conn
+	closed bool
+}
+
+var _ storage.Backend = (*Client)(nil)
+
+type Options struct {
+	ServerAddress     string
+	Username          string
+	AuthMethods       []string // AuthAgent, AuthPublicKey 를 시도할 순서대로
+	PrivateKeyPath    string
+	CertificatePath   string
+	KnownHostsPath    string
+	Protocol          string        // ProtocolSCP 또는 ProtocolSFTP
+	KeepAliveInterval time.Duration // 0 이하이면 keepalive 를 보내지 않음
+	ReconnectAttempts int
+	TransferStateDir  string // 이어서 전송하기 위한 기록을 둘 곳, 비어있으면 기록하지 않음
+	JumpHosts         []JumpHost
+}
+
+// JumpHost 서버에 접속하기 위해 거쳐가는 호스트 (OpenSSH 의 ProxyJump)
+type JumpHost struct {
+	ServerAddress   string
+	Username        string
+	AuthMethods     []string
+	PrivateKeyPath  string
+	CertificatePath string
 }
 
-func NewClient(serverAddr, username, privateKeyPath string) (*Client, error) {
-	cfg, err := auth.PrivateKey(username, privateKeyPath, ssh.InsecureIgnoreHostKey())
+func NewClient(opts Options) (_ *Client, err error) {
+	hostKeyCallback, err := newHostKeyCallback(opts.KnownHostsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host key callback: %w", err)
+	}
+	// 다시 연결할 때도 ssh-agent 를 쓰므로 연결은 Close 에서 닫음
+	auth := newAuthBuilder()
+	defer func() {
+		if err != nil {
+			auth.close()
+		}
+	}()
+	newHop := func(addr, username string, methods []string, privateKeyPath, certificatePath string) (hop, error) {
+		authMethods, err := auth.authMethods(methods, privateKeyPath, certificatePath)
+		if err != nil {
+			return hop{}, fmt.Errorf("failed to create auth config for %s: %w", addr, err)
+		}
+		return hop{
+			addr: addr,
+			config: &ssh.ClientConfig{
+				User:              username,
+				Auth:              authMethods,
+				HostKeyCallback:   hostKeyCallback,
+				HostKeyAlgorithms: knownHostKeyAlgorithms(opts.KnownHostsPath, addr),
+				Timeout:           dialTimeout,
+			},
+		}, nil
+	}
+
+	c := &Client{opts: opts, auth: auth, ops: sem.NewSemaphore(maxConcurrentOps)}
+	for _, jump := range opts.JumpHosts {
+		h, err := newHop(jump.ServerAddress, jump.Username, jump.AuthMethods, jump.PrivateKeyPath, jump.CertificatePath)
+		if err != nil {
+			return nil, err
+		}
+		c.hops = append(c.hops, h)
+	}
+	h, err := newHop(opts.ServerAddress, opts.Username, opts.AuthMethods, opts.PrivateKeyPath, opts.CertificatePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create auth config: %w", err)
+		return nil, err
 	}
-	client := goscp.NewClient(serverAddr, &cfg)
-	if err := client.Connect(); err != nil {
-		client.Close()
-		return nil, fmt.Errorf("failed to connect to SCP server: %w", err)
+	c.hops = append(c.hops, h)
+
+	cn, err := c.dial()
+	if err != nil {
+		return nil, err
 	}
-	return &Client{scpClient: &client}, nil
+	c.conn = cn
+	return c, nil
 }
 
 func (c *Client) Close() {
-	if c.scpClient != nil {
-		c.scpClient.Close()
-		c.scpClient = nil
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
 	}
+	c.auth.close()
 }
 
-func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string) error {
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath, hash string) error {
 	localPath = filepath.Clean(localPath)
+	remotedir := path.Dir(remotePath)
+	remotename := path.Base(remotePath)
+	tempfile := path.Join(remotedir, remotename+".uploading")
+
 	r, err := gzipio.NewCompressReader(localPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("%w: %s", ErrNoSuchFile, localPath)
+			return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, localPath)
 		}
 		return fmt.Errorf("failed to create compress reader for %s: %w", localPath, err)
 	}
+	defer r.Close()
 
-	if err := c.ensureRemoteDir(remotePath); err != nil {
-		return fmt.Errorf("failed to ensure remote directory: %w", err)
+	if hash != "" && r.Hash() != hash {
+		return fmt.Errorf("%w: %s has changed since it was scanned", storage.ErrHashMismatch, localPath)
 	}
 
-	remotedir := path.Dir(remotePath)
-	remotename := path.Base(remotePath)
-	tempfile := path.Join(remotedir, remotename+".uploading")
+	progressPath := c.progressPath("upload", remotePath)
+	return c.do(ctx, func(fs remoteFS) error {
+		if err := ensureRemoteDir(fs, remotePath); err != nil {
+			return fmt.Errorf("failed to ensure remote directory: %w", err)
+		}
 
-	if err := c.scpClient.CopyFile(ctx, r, tempfile, "0644"); err != nil {
-		return fmt.Errorf("failed to upload file %s to %s: %w", localPath, remotePath, err)
-	}
+		offset := resumeUploadOffset(fs, tempfile, progressPath, r)
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek compressed %s: %w", localPath, err)
+		}
 
-	_ = c.execRemote(fmt.Sprintf(`rm -f "%s"`, remotePath))
-	if err := c.execRemote(fmt.Sprintf(`mv "%s" "%s"`, tempfile, remotePath)); err != nil {
-		return fmt.Errorf("failed to rename %s to %s: %w", tempfile, remotePath, err)
-	}
-	return nil
+		for offset < r.Size() {
+			n := min(transferChunkSize, r.Size()-offset)
+			chunk := io.LimitReader(r, n)
+			if offset == 0 {
+				err = fs.WriteFile(ctx, tempfile, chunk, n)
+			} else {
+				err = fs.AppendFile(ctx, tempfile, chunk, n)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to upload file %s to %s: %w", localPath, remotePath, err)
+			}
+			offset += n
+			saveProgress(progressPath, &transferProgress{Hash: r.CompressedHash(), Size: r.Size(), Offset: offset})
+		}
+
+		info, err := fs.Stat(tempfile)
+		if err != nil {
+			return fmt.Errorf("failed to stat uploaded file %s: %w", tempfile, err)
+		}
+		if info.Size != r.Size() {
+			removeProgress(progressPath)
+			return fmt.Errorf("uploaded file %s has %d bytes, expected %d", tempfile, info.Size, r.Size())
+		}
+
+		_ = fs.Remove(remotePath)
+		if err := fs.Rename(tempfile, remotePath); err != nil {
+			return fmt.Errorf("failed to rename %s to %s: %w", tempfile, remotePath, err)
+		}
+		removeProgress(progressPath)
+		return nil
+	})
 }
 
-func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string, modTime int64) error {
+func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string, modTime int64, hash string) error {
 	localPath, err := filepath.Abs(localPath)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for %s: %w", localPath, err)
@@ -89,6 +195,7 @@ func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string,
 
 	localname := filepath.Base(localPath)
 	tempfile := filepath.Join(localdir, localname+".download")
+	partial := tempfile + ".gz" // 이어받기를 위해 실패해도 남겨둠
 	success := false
 	defer func() {
 		if !success {
@@ -96,25 +203,76 @@ func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string,
 		}
 	}()
 
-	err = func() error {
-		w := gzipio.NewDecompressWriter(tempfile)
-
-		if err := c.scpClient.CopyFromRemotePassThru(ctx, w, remotePath, nil); err != nil {
-			if strings.Contains(err.Error(), "No such file or directory") {
-				return fmt.Errorf("%w: %s", ErrNoSuchFile, remotePath)
+	progressPath := c.progressPath("download", remotePath+"\n"+localPath)
+	var got string
+	err = c.do(ctx, func(fs remoteFS) error {
+		info, err := fs.Stat(remotePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
 			}
-			return fmt.Errorf("failed to download file from %s to %s: %w", remotePath, tempfile, err)
+			return fmt.Errorf("failed to stat remote file %s: %w", remotePath, err)
 		}
-		if err := w.Close(); err != nil {
-			return fmt.Errorf("failed to close decompress writer: %w", err)
+
+		offset := resumeDownloadOffset(partial, progressPath, info)
+		progress := &transferProgress{Size: info.Size, ModTime: info.ModTime.UnixNano(), Offset: offset}
+		saveProgress(progressPath, progress)
+
+		f, err := os.OpenFile(partial, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", partial, err)
 		}
+		defer f.Close()
 
+		dw, err := resumeDecompress(f, tempfile, progress)
+		if err != nil {
+			return fmt.Errorf("failed to resume %s: %w", partial, err)
+		}
+		defer dw.CloseWithError(errors.New("download aborted"))
+
+		w := &progressWriter{f: f, progressPath: progressPath, progress: progress}
+		readErr := fs.ReadFile(ctx, remotePath, progress.Offset, io.MultiWriter(w, dw))
+		if err := w.save(); err != nil && readErr == nil {
+			readErr = err
+		}
+		if err := f.Close(); err != nil && readErr == nil {
+			readErr = err
+		}
+		if readErr != nil {
+			if dw.Failed() {
+				// 받은 내용이 깨졌으므로 이어받지 않음
+				_ = os.Remove(partial)
+				removeProgress(progressPath)
+				return fmt.Errorf("failed to decompress %s: %w", partial, readErr)
+			}
+			if errors.Is(readErr, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+			}
+			return fmt.Errorf("failed to download file from %s to %s: %w", remotePath, partial, readErr)
+		}
+		if progress.Offset != info.Size {
+			_ = os.Remove(partial)
+			removeProgress(progressPath)
+			return fmt.Errorf("downloaded file %s has %d bytes, expected %d", partial, progress.Offset, info.Size)
+		}
+		if err := dw.Close(); err != nil {
+			_ = os.Remove(partial)
+			removeProgress(progressPath)
+			return fmt.Errorf("failed to decompress %s: %w", partial, err)
+		}
+		got = dw.Hash()
 		return nil
-	}()
+	})
 	if err != nil {
 		return err
 	}
 
+	_ = os.Remove(partial)
+	removeProgress(progressPath)
+	if hash != "" && got != hash {
+		return fmt.Errorf("%w: %s", storage.ErrHashMismatch, remotePath)
+	}
+
 	_ = os.Remove(localPath) // Ignore error if file doesn't exist
 	if err := os.Rename(tempfile, localPath); err != nil {
 		return fmt.Errorf("failed to rename temporary file %s to %s: %w", tempfile, localPath, err)
@@ -130,58 +288,126 @@ func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string,
 }
 
 func (c *Client) DeleteRemoteFile(remotePath string) error {
-	if err := c.execRemote(fmt.Sprintf(`rm -f "%s"`, remotePath)); err != nil {
-		return fmt.Errorf("failed to delete remote file %s: %w", remotePath, err)
-	}
-	return nil
+	return c.do(context.Background(), func(fs remoteFS) error {
+		if err := fs.Remove(remotePath); err != nil {
+			return fmt.Errorf("failed to delete remote file %s: %w", remotePath, err)
+		}
+		return nil
+	})
+}
+
+func (c *Client) DeleteRemoteDir(remoteDir string) error {
+	return c.do(context.Background(), func(fs remoteFS) error {
+		if err := fs.RemoveAll(remoteDir); err != nil {
+			return fmt.Errorf("failed to delete remote directory %s: %w", remoteDir, err)
+		}
+		return nil
+	})
 }
 
 func (c *Client) MoveRemoteFile(oldRemotePath, newRemotePath string) error {
-	_ = c.execRemote(fmt.Sprintf(`rm -f "%s"`, newRemotePath))
-	if err := c.ensureRemoteDir(newRemotePath); err != nil {
-		return fmt.Errorf("failed to ensure remote directory for %s: %w", newRemotePath, err)
-	}
-	if err := c.execRemote(fmt.Sprintf(`mv "%s" "%s"`, oldRemotePath, newRemotePath)); err != nil {
-		return fmt.Errorf("failed to rename remote file %s: %w", oldRemotePath, err)
-	}
-	return nil
+	return c.do(context.Background(), func(fs remoteFS) error {
+		if err := ensureRemoteDir(fs, newRemotePath); err != nil {
+			return fmt.Errorf("failed to ensure remote directory for %s: %w", newRemotePath, err)
+		}
+		if err := fs.Rename(oldRemotePath, newRemotePath); err != nil {
+			return fmt.Errorf("failed to rename remote file %s: %w", oldRemotePath, err)
+		}
+		return nil
+	})
 }
 
-func (c *Client) execRemote(command string) error {
-	session, err := c.scpClient.SSHClient().NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create SSH session: %w", err)
-	}
-	defer session.Close()
-	return session.Run(command)
+func (c *Client) LinkRemoteFile(oldRemotePath, newRemotePath string) error {
+	return c.do(context.Background(), func(fs remoteFS) error {
+		if err := ensureRemoteDir(fs, newRemotePath); err != nil {
+			return fmt.Errorf("failed to ensure remote directory for %s: %w", newRemotePath, err)
+		}
+		if err := fs.Link(oldRemotePath, newRemotePath); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, oldRemotePath)
+			}
+			return fmt.Errorf("failed to link remote file %s to %s: %w", oldRemotePath, newRemotePath, err)
+		}
+		return nil
+	})
+}
+
+func (c *Client) CreateRemoteFile(remotePath string, data []byte) error {
+	return c.do(context.Background(), func(fs remoteFS) error {
+		if err := ensureRemoteDir(fs, remotePath); err != nil {
+			return fmt.Errorf("failed to ensure remote directory for %s: %w", remotePath, err)
+		}
+		if err := fs.CreateFile(remotePath, bytes.NewReader(data)); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("%w: %s", storage.ErrFileExists, remotePath)
+			}
+			return fmt.Errorf("failed to create remote file %s: %w", remotePath, err)
+		}
+		return nil
+	})
+}
+
+func (c *Client) ReadRemoteFile(remotePath string) ([]byte, error) {
+	var data []byte
+	err := c.do(context.Background(), func(fs remoteFS) error {
+		var buf bytes.Buffer
+		if err := fs.ReadFile(context.Background(), remotePath, 0, &buf); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+			}
+			return fmt.Errorf("failed to read remote file %s: %w", remotePath, err)
+		}
+		data = buf.Bytes()
+		return nil
+	})
+	return data, err
+}
+
+func (c *Client) StatRemoteFile(remotePath string) (*storage.FileInfo, error) {
+	var info *storage.FileInfo
+	err := c.do(context.Background(), func(fs remoteFS) error {
+		var err error
+		info, err = fs.Stat(remotePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remotePath)
+			}
+			return fmt.Errorf("failed to stat remote file %s: %w", remotePath, err)
+		}
+		return nil
+	})
+	return info, err
+}
+
+func (c *Client) ListRemoteDir(remoteDir string) ([]*storage.FileInfo, error) {
+	var infos []*storage.FileInfo
+	err := c.do(context.Background(), func(fs remoteFS) error {
+		var err error
+		infos, err = fs.List(remoteDir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("%w: %s", storage.ErrNoSuchFile, remoteDir)
+			}
+			return fmt.Errorf("failed to list remote directory %s: %w", remoteDir, err)
+		}
+		return nil
+	})
+	return infos, err
 }
 
-func (c *Client) ensureRemoteDir(remotePath string) error {
+func ensureRemoteDir(fs remoteFS, remotePath string) error {
 	remoteDir := path.Dir(remotePath)
 	if remoteDir == "." || remoteDir == "/" {
 		return nil // No need to create root directory
 	}
 
-	if err := c.execRemote(fmt.Sprintf(`mkdir -p "%s"`, remoteDir)); err != nil {
+	if err := fs.MkdirAll(remoteDir); err != nil {
 		return fmt.Errorf("failed to create remote directory %s: %w", remoteDir, err)
 	}
 
 	return nil
 }
 
-type contextClientKey struct{}
-
-func NewContextWithClient(ctx context.Context, client *Client) context.Context {
-	return context.WithValue(ctx, contextClientKey{}, client)
-}
-
-func ClientFromContext(ctx context.Context) *Client {
-	if client, ok := ctx.Value(contextClientKey{}).(*Client); ok {
-		return client
-	}
-	return nil
-}
-
 func DeleteLocalFile(localPath string) error {
 	localPath = filepath.Clean(localPath)
 	if err := os.Remove(localPath); err != nil {
@@ -190,12 +416,24 @@ func DeleteLocalFile(localPath string) error {
 	return nil
 }
 
+// MoveLocalFile 파일을 옮김, 다른 볼륨이라 rename 할 수 없으면 복사하고 수정 시간을 맞춤
 func MoveLocalFile(oldLocalPath, newLocalPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newLocalPath), 0755); err != nil {
+		return fmt.Errorf("failed to create local directory for %s: %w", newLocalPath, err)
+	}
+	if err := os.Rename(oldLocalPath, newLocalPath); err == nil {
+		return nil
+	}
+
 	in, err := os.Open(oldLocalPath)
 	if err != nil {
 		return fmt.Errorf("failed to open local file %s: %w", oldLocalPath, err)
 	}
 	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat local file %s: %w", oldLocalPath, err)
+	}
 	out, err := os.Create(newLocalPath)
 	if err != nil {
 		return fmt.Errorf("failed to create local file %s: %w", newLocalPath, err)
@@ -207,6 +445,9 @@ func MoveLocalFile(oldLocalPath, newLocalPath string) error {
 	if err := out.Close(); err != nil {
 		return fmt.Errorf("failed to close local file %s: %w", newLocalPath, err)
 	}
+	if err := os.Chtimes(newLocalPath, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to set modification time of %s: %w", newLocalPath, err)
+	}
 	if err := in.Close(); err != nil {
 		return fmt.Errorf("failed to close local file %s: %w", oldLocalPath, err)
 	}
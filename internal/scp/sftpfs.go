@@ -0,0 +1,187 @@
+package scp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"scpsave/internal/storage"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpFS 모든 작업을 SFTP 로 처리, 셸이 없는 계정에서도 동작
+type sftpFS struct {
+	client *sftp.Client
+}
+
+func newSFTPFS(sshClient *ssh.Client) (remoteFS, error) {
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SFTP client: %w", err)
+	}
+	return &sftpFS{client: client}, nil
+}
+
+func (s *sftpFS) Close() {
+	s.client.Close()
+}
+
+func (s *sftpFS) WriteFile(ctx context.Context, remotePath string, r io.Reader, size int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := s.client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sftpFS) CreateFile(remotePath string, r io.Reader) error {
+	f, err := s.client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		// SFTP 서버는 이미 있는 파일을 일반 실패로 알려주는 경우가 많음
+		if _, statErr := s.client.Stat(remotePath); statErr == nil {
+			return fmt.Errorf("%w: %s", os.ErrExist, remotePath)
+		}
+		return err
+	}
+	defer f.Close()
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sftpFS) AppendFile(ctx context.Context, remotePath string, r io.Reader, size int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := s.client.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// O_APPEND 를 무시하는 서버가 있으므로 직접 끝으로 이동
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sftpFS) ReadFile(ctx context.Context, remotePath string, offset int64, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, err := s.client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := f.WriteTo(w); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *sftpFS) Truncate(remotePath string, size int64) error {
+	return s.client.Truncate(remotePath, size)
+}
+
+func (s *sftpFS) Remove(remotePath string) error {
+	if err := s.client.Remove(remotePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *sftpFS) RemoveAll(remoteDir string) error {
+	if err := s.client.RemoveAll(remoteDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *sftpFS) Rename(oldRemotePath, newRemotePath string) error {
+	if _, ok := s.client.HasExtension("posix-rename@openssh.com"); ok {
+		return s.client.PosixRename(oldRemotePath, newRemotePath)
+	}
+	// 기본 SFTP rename 은 대상이 있으면 실패하므로 지우고 바꿈, 이 경우에는 원자적이지 않음
+	if err := s.Remove(newRemotePath); err != nil {
+		return err
+	}
+	return s.client.Rename(oldRemotePath, newRemotePath)
+}
+
+func (s *sftpFS) Link(oldRemotePath, newRemotePath string) error {
+	if err := s.Remove(newRemotePath); err != nil {
+		return err
+	}
+	if _, ok := s.client.HasExtension("hardlink@openssh.com"); ok {
+		if err := s.client.Link(oldRemotePath, newRemotePath); err == nil {
+			return nil
+		}
+	}
+
+	// 하드 링크를 지원하지 않으면 서버를 거쳐서 복사
+	in, err := s.client.Open(oldRemotePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := s.client.OpenFile(newRemotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := in.WriteTo(out); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func (s *sftpFS) MkdirAll(remoteDir string) error {
+	return s.client.MkdirAll(remoteDir)
+}
+
+func (s *sftpFS) Stat(remotePath string) (*storage.FileInfo, error) {
+	info, err := s.client.Stat(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	return toRemoteFileInfo(info), nil
+}
+
+func (s *sftpFS) List(remoteDir string) ([]*storage.FileInfo, error) {
+	entries, err := s.client.ReadDir(remoteDir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*storage.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		infos = append(infos, toRemoteFileInfo(entry))
+	}
+	return infos, nil
+}
+
+func toRemoteFileInfo(info os.FileInfo) *storage.FileInfo {
+	return &storage.FileInfo{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
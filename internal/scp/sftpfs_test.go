@@ -0,0 +1,184 @@
+package scp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newTestSFTPFS 같은 프로세스의 SFTP 서버에 연결한 sftpFS 와 서버의 임시 디렉터리
+func newTestSFTPFS(t *testing.T) (*sftpFS, string) {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &sftpFS{client: client}
+	// 서버 쪽을 먼저 닫아야 클라이언트가 응답을 기다리지 않고 끝남
+	t.Cleanup(func() {
+		server.Close()
+		fs.Close()
+	})
+	return fs, filepath.ToSlash(t.TempDir())
+}
+
+func readTestRemoteFile(t *testing.T, fs remoteFS, remotePath string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := fs.ReadFile(context.Background(), remotePath, 0, &buf); err != nil {
+		t.Fatalf("ReadFile(%s) failed: %v", remotePath, err)
+	}
+	return buf.String()
+}
+
+func TestSFTPFSHostileNames(t *testing.T) {
+	fs, root := newTestSFTPFS(t)
+	ctx := context.Background()
+	dir := path.Join(root, "$(id)", "`x`")
+	if err := fs.MkdirAll(dir); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+
+	for _, name := range hostileNames {
+		p := path.Join(dir, name)
+		if err := fs.WriteFile(ctx, p, strings.NewReader(name), int64(len(name))); err != nil {
+			t.Fatalf("WriteFile(%q) failed: %v", name, err)
+		}
+		if got := readTestRemoteFile(t, fs, p); got != name {
+			t.Errorf("%q has %q", name, got)
+		}
+		moved := p + ".moved"
+		if err := fs.Rename(p, moved); err != nil {
+			t.Fatalf("Rename(%q) failed: %v", name, err)
+		}
+		if _, err := fs.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat(%q) after rename = %v, want os.ErrNotExist", name, err)
+		}
+		if err := fs.Remove(moved); err != nil {
+			t.Errorf("Remove(%q) failed: %v", name, err)
+		}
+	}
+	if infos, err := fs.List(dir); err != nil || len(infos) != 0 {
+		t.Errorf("List = %v, %v, want empty", infos, err)
+	}
+}
+
+func TestSFTPFSCreateAndAppend(t *testing.T) {
+	fs, root := newTestSFTPFS(t)
+	ctx := context.Background()
+	p := path.Join(root, "lock")
+
+	if err := fs.CreateFile(p, strings.NewReader("first")); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	if err := fs.CreateFile(p, strings.NewReader("second")); !errors.Is(err, os.ErrExist) {
+		t.Errorf("second CreateFile = %v, want os.ErrExist", err)
+	}
+	if err := fs.AppendFile(ctx, p, strings.NewReader("+more"), 5); err != nil {
+		t.Fatalf("AppendFile failed: %v", err)
+	}
+	if got := readTestRemoteFile(t, fs, p); got != "first+more" {
+		t.Errorf("content = %q, want first+more", got)
+	}
+
+	var buf bytes.Buffer
+	if err := fs.ReadFile(ctx, p, 6, &buf); err != nil || buf.String() != "more" {
+		t.Errorf("ReadFile from offset = %q, %v, want more", buf.String(), err)
+	}
+	if err := fs.Truncate(p, 5); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+	if info, err := fs.Stat(p); err != nil || info.Size != 5 || info.IsDir {
+		t.Errorf("Stat = %+v, %v, want a 5 byte file", info, err)
+	}
+}
+
+func TestSFTPFSRenameReplaces(t *testing.T) {
+	fs, root := newTestSFTPFS(t)
+	ctx := context.Background()
+	from, to := path.Join(root, "new"), path.Join(root, "current")
+	for p, content := range map[string]string{from: "new", to: "old"} {
+		if err := fs.WriteFile(ctx, p, strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fs.Rename(from, to); err != nil {
+		t.Fatalf("Rename over an existing file failed: %v", err)
+	}
+	if got := readTestRemoteFile(t, fs, to); got != "new" {
+		t.Errorf("content = %q, want new", got)
+	}
+}
+
+func TestSFTPFSLinkAndRemoveAll(t *testing.T) {
+	fs, root := newTestSFTPFS(t)
+	ctx := context.Background()
+	dir := path.Join(root, "history", "g1")
+	if err := fs.MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	src := path.Join(root, "blob")
+	if err := fs.WriteFile(ctx, src, strings.NewReader("blob"), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a.sav", "b.sav"} {
+		if err := fs.Link(src, path.Join(dir, name)); err != nil {
+			t.Fatalf("Link failed: %v", err)
+		}
+	}
+	// 이미 있는 대상도 바꿈
+	if err := fs.Link(src, path.Join(dir, "a.sav")); err != nil {
+		t.Fatalf("Link over an existing file failed: %v", err)
+	}
+	infos, err := fs.List(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.sav,b.sav" {
+		t.Errorf("List = %v, want a.sav and b.sav", names)
+	}
+
+	if err := fs.RemoveAll(path.Join(root, "history")); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := fs.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Stat after RemoveAll = %v, want os.ErrNotExist", err)
+	}
+	// 없는 경로를 지우는 것은 성공
+	if err := fs.Remove(path.Join(root, "missing")); err != nil {
+		t.Errorf("Remove of a missing file = %v", err)
+	}
+	if err := fs.RemoveAll(path.Join(root, "missing")); err != nil {
+		t.Errorf("RemoveAll of a missing directory = %v", err)
+	}
+	if _, err := fs.List(path.Join(root, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("List of a missing directory = %v, want os.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,270 @@
+package scp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"scpsave/internal/storage"
+	"strconv"
+	"strings"
+	"time"
+
+	goscp "github.com/bramvdbogaerde/go-scp"
+	"golang.org/x/crypto/ssh"
+)
+
+// shellFS scp 로 파일을 전송하고 나머지 작업은 원격 셸 명령으로 처리
+// 셸 명령의 경로는 항상 quoteRemotePath 로 인용해서 넣음
+type shellFS struct {
+	sshClient *ssh.Client
+	scpClient *goscp.Client
+}
+
+func newShellFS(sshClient *ssh.Client) (remoteFS, error) {
+	client, err := goscp.NewClientBySSH(sshClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SCP client: %w", err)
+	}
+	return &shellFS{sshClient: sshClient, scpClient: &client}, nil
+}
+
+func (s *shellFS) Close() {}
+
+func (s *shellFS) WriteFile(ctx context.Context, remotePath string, r io.Reader, size int64) error {
+	if scpSafePath(remotePath) {
+		return s.copyTo(ctx, r, remotePath, size)
+	}
+	temp := scpTempPath(remotePath, ".uploading")
+	if err := s.copyTo(ctx, r, temp, size); err != nil {
+		_ = s.Remove(temp)
+		return err
+	}
+	return s.Rename(temp, remotePath)
+}
+
+func (s *shellFS) CreateFile(remotePath string, r io.Reader) error {
+	// noclobber 로 O_EXCL 을 사용해서 만듦
+	command, err := remoteCommand(`set -C && cat > %s`, remotePath)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, r, nil)
+	return err
+}
+
+// AppendFile scp 는 이어 쓸 수 없으므로 조각 파일로 보낸 뒤 원격에서 붙임
+func (s *shellFS) AppendFile(ctx context.Context, remotePath string, r io.Reader, size int64) error {
+	part := scpTempPath(remotePath, ".part")
+	command, err := remoteCommand(`cat %s >> %s && rm -f %s`, part, remotePath, part)
+	if err != nil {
+		return err
+	}
+	if err := s.copyTo(ctx, r, part, size); err != nil {
+		_ = s.Remove(part)
+		return err
+	}
+	_, err = s.run(ctx, command, nil, nil)
+	return err
+}
+
+// ReadFile 이어받을 때는 원격에서 offset 뒤의 내용을 조각 파일로 잘라서 scp 로 받음
+func (s *shellFS) ReadFile(ctx context.Context, remotePath string, offset int64, w io.Writer) error {
+	if offset == 0 && scpSafePath(remotePath) {
+		return s.copyFrom(ctx, w, remotePath)
+	}
+
+	part := scpTempPath(remotePath, ".part")
+	format := `cat %s > %s`
+	if offset > 0 {
+		format = fmt.Sprintf(`tail -c +%d %%s > %%s`, offset+1)
+	}
+	command, err := remoteCommand(format, remotePath, part)
+	if err != nil {
+		return err
+	}
+	defer s.Remove(part)
+	if _, err := s.run(ctx, command, nil, nil); err != nil {
+		return err
+	}
+	return s.copyFrom(ctx, w, part)
+}
+
+func (s *shellFS) copyTo(ctx context.Context, r io.Reader, remotePath string, size int64) error {
+	return s.scpClient.CopyPassThru(ctx, r, remotePath, "0644", size, nil)
+}
+
+func (s *shellFS) copyFrom(ctx context.Context, w io.Writer, remotePath string) error {
+	if err := s.scpClient.CopyFromRemotePassThru(ctx, w, remotePath, nil); err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return fmt.Errorf("%w: %s", os.ErrNotExist, remotePath)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *shellFS) Truncate(remotePath string, size int64) error {
+	command, err := remoteCommand(fmt.Sprintf(`truncate -s %d %%s`, size), remotePath)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) Remove(remotePath string) error {
+	command, err := remoteCommand(`rm -f %s`, remotePath)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) RemoveAll(remoteDir string) error {
+	command, err := remoteCommand(`rm -rf %s`, remoteDir)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) Rename(oldRemotePath, newRemotePath string) error {
+	command, err := remoteCommand(`mv -f %s %s`, oldRemotePath, newRemotePath)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) Link(oldRemotePath, newRemotePath string) error {
+	command, err := remoteCommand(`ln -f %s %s 2>/dev/null || cp -f %s %s`, oldRemotePath, newRemotePath, oldRemotePath, newRemotePath)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) MkdirAll(remoteDir string) error {
+	command, err := remoteCommand(`mkdir -p %s`, remoteDir)
+	if err != nil {
+		return err
+	}
+	_, err = s.run(context.Background(), command, nil, nil)
+	return err
+}
+
+func (s *shellFS) Stat(remotePath string) (*storage.FileInfo, error) {
+	command, err := remoteCommand(`stat -c '%%s/%%Y/%%F/%%n' %s`, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.run(context.Background(), command, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := parseStatOutput(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) != 1 {
+		return nil, fmt.Errorf("unexpected stat output for %s: %q", remotePath, out)
+	}
+	return infos[0], nil
+}
+
+func (s *shellFS) List(remoteDir string) ([]*storage.FileInfo, error) {
+	command, err := remoteCommand(`find %s -mindepth 1 -maxdepth 1 -exec stat -c '%%s/%%Y/%%F/%%n' {} +`, remoteDir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.run(context.Background(), command, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseStatOutput(out)
+}
+
+// run 원격 명령을 실행, stdout 이 nil 이면 출력을 모아서 반환
+func (s *shellFS) run(ctx context.Context, command string, stdin io.Reader, stdout io.Writer) ([]byte, error) {
+	session, err := s.sshClient.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+
+	var out, stderr bytes.Buffer
+	if stdout == nil {
+		stdout = &out
+	}
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = &stderr
+
+	if err := session.Start(command); err != nil {
+		return nil, fmt.Errorf("failed to start remote command: %w", err)
+	}
+
+	errch := make(chan error, 1)
+	go func() {
+		errch <- session.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		session.Close()
+		<-errch
+		return nil, ctx.Err()
+	case err = <-errch:
+	}
+
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(msg, "No such file") {
+			return nil, fmt.Errorf("%w: %s", os.ErrNotExist, msg)
+		}
+		if strings.Contains(msg, "File exists") || strings.Contains(msg, "cannot overwrite existing file") {
+			return nil, fmt.Errorf("%w: %s", os.ErrExist, msg)
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// parseStatOutput `stat -c '%s/%Y/%F/%n'` 출력을 해석
+func parseStatOutput(out []byte) ([]*storage.FileInfo, error) {
+	var infos []*storage.FileInfo
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "/", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected stat output: %q", line)
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in stat output %q: %w", line, err)
+		}
+		mtime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modification time in stat output %q: %w", line, err)
+		}
+		infos = append(infos, &storage.FileInfo{
+			Name:    path.Base(fields[3]),
+			Size:    size,
+			ModTime: time.Unix(mtime, 0),
+			IsDir:   fields[2] == "directory",
+		})
+	}
+	return infos, nil
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoSuchFile   = errors.New("no such file or directory")
+	ErrHashMismatch = errors.New("file hash mismatch")
+	ErrFileExists   = errors.New("file already exists")
+)
+
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// Backend 원격 저장소
+// 원격 경로는 '/' 로 구분하며, 경로가 없으면 ErrNoSuchFile 로 판별할 수 있는 에러를 반환
+// hash 가 비어있지 않으면 전송한 내용의 SHA-512 를 확인하고 다르면 ErrHashMismatch 를 반환
+type Backend interface {
+	UploadFile(ctx context.Context, localPath, remotePath, hash string) error
+	DownloadFile(ctx context.Context, remotePath, localPath string, modTime int64, hash string) error
+	// MoveRemoteFile newRemotePath 가 있으면 rename 으로 한 번에 교체
+	MoveRemoteFile(oldRemotePath, newRemotePath string) error
+	// LinkRemoteFile newRemotePath 를 oldRemotePath 와 같은 내용으로 만듦
+	// 가능하면 하드 링크로 저장 공간을 공유하므로 원격 파일은 덮어쓰지 말고 교체해야 함
+	LinkRemoteFile(oldRemotePath, newRemotePath string) error
+	DeleteRemoteFile(remotePath string) error
+	// DeleteRemoteDir 디렉터리를 안의 내용과 함께 지움, 없으면 성공으로 봄
+	DeleteRemoteDir(remoteDir string) error
+	// CreateRemoteFile 압축하지 않은 작은 파일을 만듦, 이미 있으면 ErrFileExists 를 반환
+	CreateRemoteFile(remotePath string, data []byte) error
+	// ReadRemoteFile CreateRemoteFile 로 만든 파일을 읽음
+	ReadRemoteFile(remotePath string) ([]byte, error)
+	ListRemoteDir(remoteDir string) ([]*FileInfo, error)
+	StatRemoteFile(remotePath string) (*FileInfo, error)
+	Close()
+}
+
+// Backends 원격 이름마다 처음 쓸 때 연결하고 이후에는 같은 Backend 를 씀
+// 쓰지 않는 원격에는 연결하지 않음
+type Backends struct {
+	open func(name string) (Backend, error)
+
+	mu       sync.Mutex
+	backends map[string]Backend
+}
+
+func NewBackends(open func(name string) (Backend, error)) *Backends {
+	return &Backends{
+		open:     open,
+		backends: make(map[string]Backend),
+	}
+}
+
+// Get 연결에 실패하면 다음에 다시 시도함
+// 암호 입력이 섞이지 않도록 연결은 한 번에 하나씩 함
+func (b *Backends) Get(name string) (Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if backend, exists := b.backends[name]; exists {
+		return backend, nil
+	}
+	backend, err := b.open(name)
+	if err != nil {
+		return nil, err
+	}
+	b.backends[name] = backend
+	return backend, nil
+}
+
+func (b *Backends) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, backend := range b.backends {
+		backend.Close()
+		delete(b.backends, name)
+	}
+}
+
+type contextBackendsKey struct{}
+
+func NewContextWithBackends(ctx context.Context, backends *Backends) context.Context {
+	return context.WithValue(ctx, contextBackendsKey{}, backends)
+}
+
+// BackendFromContext name 원격의 Backend, 처음이면 연결함
+func BackendFromContext(ctx context.Context, name string) (Backend, error) {
+	backends, ok := ctx.Value(contextBackendsKey{}).(*Backends)
+	if !ok {
+		return nil, errors.New("no storage backends in context")
+	}
+	return backends.Get(name)
+}